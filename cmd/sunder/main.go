@@ -1,14 +1,32 @@
 package main
 
 import (
+	"flag"
 	"fmt"
+	"os"
+	"time"
 
+	"github.com/liamg/sunder/pkg/asciicast"
+	"github.com/liamg/sunder/pkg/config"
 	"github.com/liamg/sunder/pkg/multiplexer"
 )
 
 func main() {
 
-	mp := multiplexer.New()
+	if len(os.Args) > 1 && os.Args[1] == "play" {
+		if err := play(os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	cfg, err := config.Load(config.DefaultPath())
+	if err != nil {
+		panic(err)
+	}
+
+	mp := multiplexer.New(multiplexer.WithConfig(cfg))
 	if err := mp.Start(); err != nil {
 		panic(err)
 	}
@@ -16,3 +34,22 @@ func main() {
 	// reset terminal on exit
 	fmt.Printf("\x1bc")
 }
+
+// play replays an asciicast recording to stdout
+func play(args []string) error {
+	flags := flag.NewFlagSet("play", flag.ExitOnError)
+	idleLimit := flags.Duration("idle-limit", time.Second*2, "shorten pauses longer than this (0 to disable)")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: sunder play [-idle-limit duration] <recording.cast>")
+	}
+
+	f, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	return asciicast.Play(f, os.Stdout, *idleLimit)
+}
@@ -1,12 +1,11 @@
 module github.com/liamg/sunder
 
-go 1.13
+go 1.16
 
 require (
 	github.com/creack/pty v1.1.11
 	github.com/google/uuid v1.1.1 // indirect
 	github.com/kr/pty v1.1.8 // indirect
-	github.com/liamg/termutil v0.0.0-20201124192529-c50fc538a599
 	golang.org/x/crypto v0.0.0-20201117144127-c1f2f97bffc9
 	gopkg.in/yaml.v2 v2.2.2 // indirect
 )
@@ -1,16 +1,31 @@
 package ansi
 
 import (
+	"encoding/base64"
 	"fmt"
 	"io"
+	"strings"
+
+	"github.com/liamg/sunder/pkg/capability"
 )
 
 type Writer struct {
 	writer io.Writer
+	caps   capability.Capabilities
 }
 
 func NewWriter(w io.Writer) *Writer {
-	return &Writer{writer: w}
+	return &Writer{writer: w, caps: capability.Full()}
+}
+
+// Capabilities returns what the terminal being written to can display
+func (w *Writer) Capabilities() capability.Capabilities {
+	return w.caps
+}
+
+// SetCapabilities sets what the terminal being written to can display, so output can be limited to match
+func (w *Writer) SetCapabilities(caps capability.Capabilities) {
+	w.caps = caps
 }
 
 func (w *Writer) Write(data []byte) (n int, err error) {
@@ -60,3 +75,95 @@ func (w *Writer) SetCursorVisible(visible bool) {
 	}
 	_, _ = w.Write([]byte(ctrl)) // 1-indexed
 }
+
+// SetFocusReporting enables or disables the terminal sending ESC [ I and ESC [ O to stdin when it gains and loses
+// focus (mode 1004)
+func (w *Writer) SetFocusReporting(enabled bool) {
+	if enabled {
+		_, _ = w.Write([]byte("\x1b[?1004h"))
+	} else {
+		_, _ = w.Write([]byte("\x1b[?1004l"))
+	}
+}
+
+// SetApplicationKeypad switches the keypad between sending escape sequences (DECKPAM) and the characters on its keys
+// (DECKPNM)
+func (w *Writer) SetApplicationKeypad(enabled bool) {
+	if enabled {
+		_, _ = w.Write([]byte("\x1b="))
+	} else {
+		_, _ = w.Write([]byte("\x1b>"))
+	}
+}
+
+// SetKeyboardEnhancements asks the terminal to send keys which are ambiguous in the legacy encoding, such as
+// ctrl + enter or ctrl + shift + a, as distinct escape sequences. Both the kitty keyboard protocol (disambiguate
+// escape codes) and xterm's modifyOtherKeys are requested, and terminals ignore whichever they don't support.
+func (w *Writer) SetKeyboardEnhancements(enabled bool) {
+	if enabled {
+		_, _ = w.Write([]byte("\x1b[>4;2m\x1b[>1u"))
+	} else {
+		_, _ = w.Write([]byte("\x1b[<u\x1b[>4m"))
+	}
+}
+
+// SetCursorStyle sets the shape of the cursor (DECSCUSR), where 0 is the terminal's default
+func (w *Writer) SetCursorStyle(style int) {
+	_, _ = fmt.Fprintf(w.writer, "\x1b[%d q", style)
+}
+
+// SetClipboard sets the given clipboard selection(s) of the terminal via OSC 52
+func (w *Writer) SetClipboard(selection string, data []byte) {
+	_, _ = fmt.Fprintf(w.writer, "\x1b]52;%s;%s\x07", selection, base64.StdEncoding.EncodeToString(data))
+}
+
+// Bell rings the terminal bell
+func (w *Writer) Bell() {
+	_, _ = w.Write([]byte{0x07})
+}
+
+// SetReverseVideo swaps the default foreground and background colours of the whole screen (DECSCNM)
+func (w *Writer) SetReverseVideo(enabled bool) {
+	ctrl := "\x1b[?5"
+	if enabled {
+		ctrl += "h"
+	} else {
+		ctrl += "l"
+	}
+	_, _ = w.Write([]byte(ctrl))
+}
+
+// Notify shows a desktop notification via OSC 9
+func (w *Writer) Notify(message string) {
+	_, _ = fmt.Fprintf(w.writer, "\x1b]9;%s\x07", message)
+}
+
+// NotifyWithTitle shows a desktop notification with a title via OSC 777. Semicolons separate the title from the body,
+// so any in the title are replaced with commas.
+func (w *Writer) NotifyWithTitle(title string, body string) {
+	_, _ = fmt.Fprintf(w.writer, "\x1b]777;notify;%s;%s\x07", strings.ReplaceAll(title, ";", ","), body)
+}
+
+// Synchronized updates (mode 2026) ask the terminal to draw everything between them at once
+const (
+	BeginSynchronizedUpdate = "\x1b[?2026h"
+	EndSynchronizedUpdate   = "\x1b[?2026l"
+)
+
+// QueryPrivateMode asks the terminal whether it supports a DEC private mode (DECRQM). The answer is sent to stdin.
+func (w *Writer) QueryPrivateMode(mode int) {
+	_, _ = fmt.Fprintf(w.writer, "\x1b[?%d$p", mode)
+}
+
+// QueryDynamicColours asks the terminal for its default foreground (OSC 10) and background (OSC 11) colours. The
+// answers are sent to stdin.
+func (w *Writer) QueryDynamicColours() {
+	_, _ = fmt.Fprintf(w.writer, "\x1b]10;?\x1b\\\x1b]11;?\x1b\\")
+}
+
+// QueryDeviceAttributes asks the terminal for its primary (DA1) and secondary (DA2) device attributes, and its name
+// and version (XTVERSION). The answers are sent to stdin.
+func (w *Writer) QueryDeviceAttributes() {
+	// DA1 goes last, as every terminal answers it
+	_, _ = fmt.Fprintf(w.writer, "\x1b[>0q\x1b[>c\x1b[c")
+}
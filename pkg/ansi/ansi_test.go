@@ -0,0 +1,35 @@
+package ansi
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestNotifications(t *testing.T) {
+	tests := []struct {
+		name     string
+		title    string
+		body     string
+		expected string
+	}{
+		{"without a title", "", "done", "\x1b]9;done\x07"},
+		{"with a title", "build", "done", "\x1b]777;notify;build;done\x07"},
+		{"semicolons in the title", "a;b;c", "done", "\x1b]777;notify;a,b,c;done\x07"},
+		{"semicolons in the body", "build", "a;b", "\x1b]777;notify;build;a;b\x07"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			var buffer bytes.Buffer
+			w := NewWriter(&buffer)
+			if test.title == "" {
+				w.Notify(test.body)
+			} else {
+				w.NotifyWithTitle(test.title, test.body)
+			}
+			if actual := buffer.String(); actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package asciicast
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Play reads an asciicast v2 recording from r and writes the output events to w in real time. Pauses longer than
+// maxIdle are shortened to maxIdle, unless maxIdle is zero.
+func Play(r io.Reader, w io.Writer, maxIdle time.Duration) error {
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 0xffff), 0xffffff)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("recording is empty")
+	}
+
+	var header Header
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return fmt.Errorf("invalid asciicast header: %s", err)
+	}
+	if header.Version != 2 {
+		return fmt.Errorf("unsupported asciicast version: %d", header.Version)
+	}
+
+	start := time.Now()
+	var lastEvent, skipped time.Duration
+
+	for scanner.Scan() {
+
+		var event []interface{}
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return fmt.Errorf("invalid asciicast event: %s", err)
+		}
+		if len(event) != 3 {
+			return fmt.Errorf("invalid asciicast event: expected 3 elements, found %d", len(event))
+		}
+		seconds, ok := event[0].(float64)
+		if !ok {
+			return fmt.Errorf("invalid asciicast event time: %v", event[0])
+		}
+		eventType, _ := event[1].(string)
+		data, _ := event[2].(string)
+
+		at := time.Duration(seconds * float64(time.Second))
+		if maxIdle > 0 && at-lastEvent > maxIdle {
+			skipped += at - lastEvent - maxIdle
+		}
+		lastEvent = at
+
+		time.Sleep(time.Until(start.Add(at - skipped)))
+
+		if eventType != EventOutput {
+			continue
+		}
+		if _, err := io.WriteString(w, data); err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
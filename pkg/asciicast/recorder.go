@@ -0,0 +1,120 @@
+package asciicast
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Header is the first line of an asciicast v2 file
+type Header struct {
+	Version   int               `json:"version"`
+	Width     uint16            `json:"width"`
+	Height    uint16            `json:"height"`
+	Timestamp int64             `json:"timestamp,omitempty"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+}
+
+const (
+	EventOutput = "o"
+	EventInput  = "i"
+	EventResize = "r"
+)
+
+// Recorder writes an asciicast v2 recording of everything written to it
+type Recorder struct {
+	writer  io.WriteCloser
+	start   time.Time
+	lock    sync.Mutex
+	partial []byte
+}
+
+// NewRecorder writes the asciicast header to w and returns a Recorder which records output to it
+func NewRecorder(w io.WriteCloser, width, height uint16, title string) (*Recorder, error) {
+	start := time.Now()
+	header, err := json.Marshal(Header{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: start.Unix(),
+		Title:     title,
+		Env: map[string]string{
+			"SHELL": os.Getenv("SHELL"),
+			"TERM":  os.Getenv("TERM"),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := fmt.Fprintf(w, "%s\n", header); err != nil {
+		return nil, err
+	}
+	return &Recorder{
+		writer: w,
+		start:  start,
+	}, nil
+}
+
+// Write records data as an output event
+func (r *Recorder) Write(data []byte) (n int, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	// output may be split in the middle of a multi-byte character, so hold back any incomplete trailing bytes
+	// until the next write, otherwise they'd be mangled when encoded as JSON
+	buffered := append(r.partial, data...)
+	complete := len(buffered)
+	for i := len(buffered) - 1; i >= 0 && i >= len(buffered)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(buffered[i]) {
+			if !utf8.FullRune(buffered[i:]) {
+				complete = i
+			}
+			break
+		}
+	}
+	r.partial = append([]byte{}, buffered[complete:]...)
+
+	if complete == 0 {
+		return len(data), nil
+	}
+	if err := r.writeEvent(EventOutput, string(buffered[:complete])); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Resize records a change in terminal size
+func (r *Recorder) Resize(width, height uint16) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.writeEvent(EventResize, fmt.Sprintf("%dx%d", width, height))
+}
+
+func (r *Recorder) writeEvent(eventType string, data string) error {
+	event, err := json.Marshal([]interface{}{
+		time.Since(r.start).Seconds(),
+		eventType,
+		data,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(r.writer, "%s\n", event)
+	return err
+}
+
+// Close flushes any buffered output and closes the underlying writer
+func (r *Recorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if len(r.partial) > 0 {
+		_ = r.writeEvent(EventOutput, string(r.partial))
+		r.partial = nil
+	}
+	return r.writer.Close()
+}
@@ -0,0 +1,124 @@
+package asciicast
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+type closingBuffer struct {
+	bytes.Buffer
+	closed bool
+}
+
+func (b *closingBuffer) Close() error {
+	b.closed = true
+	return nil
+}
+
+func TestRecorder(t *testing.T) {
+	tests := []struct {
+		name     string
+		record   func(r *Recorder)
+		expected [][2]string
+	}{
+		{
+			name: "output",
+			record: func(r *Recorder) {
+				_, _ = r.Write([]byte("hello"))
+				_, _ = r.Write([]byte(" world\r\n"))
+			},
+			expected: [][2]string{{EventOutput, "hello"}, {EventOutput, " world\r\n"}},
+		},
+		{
+			name: "resize",
+			record: func(r *Recorder) {
+				_, _ = r.Write([]byte("a"))
+				_ = r.Resize(40, 12)
+			},
+			expected: [][2]string{{EventOutput, "a"}, {EventResize, "40x12"}},
+		},
+		{
+			name: "character split across writes",
+			record: func(r *Recorder) {
+				_, _ = r.Write([]byte("a\xe6\x97"))
+				_, _ = r.Write([]byte("\xa5b"))
+			},
+			expected: [][2]string{{EventOutput, "a"}, {EventOutput, "日b"}},
+		},
+		{
+			name: "write of only part of a character",
+			record: func(r *Recorder) {
+				_, _ = r.Write([]byte("\xf0\x9f"))
+				_, _ = r.Write([]byte("\x98"))
+				_, _ = r.Write([]byte("\x80"))
+			},
+			expected: [][2]string{{EventOutput, "😀"}},
+		},
+		{
+			name: "incomplete character is flushed on close",
+			record: func(r *Recorder) {
+				_, _ = r.Write([]byte("a\xe6\x97"))
+			},
+			expected: [][2]string{{EventOutput, "a"}, {EventOutput, "\ufffd\ufffd"}},
+		},
+		{
+			name: "invalid bytes are not held back",
+			record: func(r *Recorder) {
+				_, _ = r.Write([]byte("a\x97"))
+				_, _ = r.Write([]byte("b"))
+			},
+			expected: [][2]string{{EventOutput, "a\ufffd"}, {EventOutput, "b"}},
+		},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			output := &closingBuffer{}
+			recorder, err := NewRecorder(output, 80, 24, "test")
+			if err != nil {
+				t.Fatal(err)
+			}
+			test.record(recorder)
+			if err := recorder.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if !output.closed {
+				t.Error("expected the output to be closed")
+			}
+
+			lines := strings.Split(strings.TrimSuffix(output.String(), "\n"), "\n")
+			var header Header
+			if err := json.Unmarshal([]byte(lines[0]), &header); err != nil {
+				t.Fatal(err)
+			}
+			if header.Version != 2 || header.Width != 80 || header.Height != 24 || header.Title != "test" {
+				t.Errorf("unexpected header: %+v", header)
+			}
+
+			var events [][2]string
+			for _, line := range lines[1:] {
+				var event []interface{}
+				if err := json.Unmarshal([]byte(line), &event); err != nil {
+					t.Fatalf("invalid event %q: %s", line, err)
+				}
+				if len(event) != 3 {
+					t.Fatalf("expected 3 elements in event %q", line)
+				}
+				eventType, _ := event[1].(string)
+				data, _ := event[2].(string)
+				events = append(events, [2]string{eventType, data})
+			}
+			if len(events) != len(test.expected) {
+				t.Fatalf("expected events %q, got %q", test.expected, events)
+			}
+			for i := range events {
+				if events[i] != test.expected[i] {
+					t.Errorf("expected events %q, got %q", test.expected, events)
+					break
+				}
+			}
+		})
+	}
+}
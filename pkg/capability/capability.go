@@ -0,0 +1,140 @@
+package capability
+
+import (
+	"os"
+	"strings"
+)
+
+// ColourDepth is the number of colours a terminal can display
+type ColourDepth int
+
+const (
+	Monochrome ColourDepth = 0
+	Colours8   ColourDepth = 8
+	Colours16  ColourDepth = 16
+	Colours256 ColourDepth = 256
+	TrueColour ColourDepth = 1 << 24
+)
+
+// Attribute is a text attribute a terminal may be able to display
+type Attribute uint8
+
+const (
+	Bold Attribute = 1 << iota
+	Dim
+	Underline
+	Blink
+	Inverse
+	Hidden
+
+	AllAttributes = Bold | Dim | Underline | Blink | Inverse | Hidden
+)
+
+// Capabilities describes what a terminal is able to display
+type Capabilities struct {
+	Colours    ColourDepth
+	Attributes Attribute
+	// UTF8 is false if only ASCII can be displayed
+	UTF8 bool
+	// Name is the name and version the terminal reported, if it did
+	Name string
+}
+
+// Full returns the capabilities of a modern terminal which supports everything
+func Full() Capabilities {
+	return Capabilities{
+		Colours:    TrueColour,
+		Attributes: AllAttributes,
+		UTF8:       true,
+	}
+}
+
+// FromEnvironment works out the capabilities of the terminal we are running in from $TERM, its terminfo entry,
+// $COLORTERM and the locale
+func FromEnvironment() Capabilities {
+	return detect(os.Getenv)
+}
+
+func detect(getenv func(string) string) Capabilities {
+
+	caps := Capabilities{
+		Colours:    Colours16,
+		Attributes: AllAttributes,
+		UTF8:       isUTF8Locale(getenv),
+	}
+
+	term := getenv("TERM")
+	switch {
+	case term == "" || term == "dumb":
+		caps.Colours = Monochrome
+	case strings.Contains(term, "256color"):
+		caps.Colours = Colours256
+	}
+
+	if info, err := loadTerminfo(term, getenv); err == nil {
+		caps.Colours = info.colours
+		caps.Attributes = info.attributes
+	}
+
+	switch strings.ToLower(getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		caps.Colours = TrueColour
+	}
+
+	return caps
+}
+
+// isUTF8Locale checks the locale in the same order of precedence as setlocale
+func isUTF8Locale(getenv func(string) string) bool {
+	for _, name := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if locale := getenv(name); locale != "" {
+			locale = strings.ToLower(locale)
+			return strings.Contains(locale, "utf-8") || strings.Contains(locale, "utf8")
+		}
+	}
+	return false
+}
+
+// Supports returns true if the terminal can display the attribute
+func (c Capabilities) Supports(attr Attribute) bool {
+	return c.Attributes&attr != 0
+}
+
+// terminals which report a name with XTVERSION and are known to support truecolour
+var trueColourTerminals = []string{"kitty", "WezTerm", "foot", "iTerm2", "ghostty", "contour", "mintty", "XTerm"}
+
+// ApplyVersion updates the capabilities from the reply to an XTVERSION query, e.g. "kitty(0.26.5)"
+func (c *Capabilities) ApplyVersion(version string) {
+	c.Name = version
+	for _, name := range trueColourTerminals {
+		if strings.HasPrefix(version, name) {
+			c.Colours = TrueColour
+			return
+		}
+	}
+}
+
+// ApplyPrimaryAttributes updates the capabilities from the reply to a DA1 query
+func (c *Capabilities) ApplyPrimaryAttributes(params []int) {
+	for _, param := range params {
+		// 22 is ANSI colour, which some terminals report even though they don't have a terminfo entry
+		if param == 22 && c.Colours < Colours8 {
+			c.Colours = Colours8
+		}
+	}
+}
+
+// ApplySecondaryAttributes updates the capabilities from the reply to a DA2 query, which identifies the terminal
+func (c *Capabilities) ApplySecondaryAttributes(params []int) {
+	if len(params) < 2 {
+		return
+	}
+	switch terminalType, version := params[0], params[1]; {
+	case terminalType == 77:
+		// mintty
+		c.Colours = TrueColour
+	case (terminalType == 1 || terminalType == 65) && version >= 3600:
+		// VTE (gnome-terminal and friends) has supported truecolour since 0.36
+		c.Colours = TrueColour
+	}
+}
@@ -0,0 +1,55 @@
+package capability
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	terminfo := t.TempDir()
+	entries := map[string][]byte{
+		// grouped by first letter
+		filepath.Join("s", "sunder-8"): compileTerminfo(false, "sunder-8", 2, numbersWithColours(8), 40, 27),
+		// grouped by the hex value of the first letter, as on macOS
+		filepath.Join("73", "sunder-direct"): compileTerminfo(true, "sunder-direct", 2, numbersWithColours(1<<24), 40, 27, 36),
+	}
+	for name, data := range entries {
+		path := filepath.Join(terminfo, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected Capabilities
+	}{
+		{"no terminal", map[string]string{}, Capabilities{Colours: Monochrome, Attributes: AllAttributes}},
+		{"unknown terminal", map[string]string{"TERM": "sunder-unknown"}, Capabilities{Colours: Colours16, Attributes: AllAttributes}},
+		{"unknown 256 colour terminal", map[string]string{"TERM": "sunder-unknown-256color"}, Capabilities{Colours: Colours256, Attributes: AllAttributes}},
+		{"truecolour from COLORTERM", map[string]string{"TERM": "sunder-unknown", "COLORTERM": "truecolor"}, Capabilities{Colours: TrueColour, Attributes: AllAttributes}},
+		{"24bit from COLORTERM", map[string]string{"TERM": "sunder-unknown", "COLORTERM": "24BIT"}, Capabilities{Colours: TrueColour, Attributes: AllAttributes}},
+		{"terminfo entry", map[string]string{"TERM": "sunder-8", "TERMINFO": terminfo}, Capabilities{Colours: Colours8, Attributes: Bold}},
+		{"terminfo entry in hex group", map[string]string{"TERM": "sunder-direct", "TERMINFO_DIRS": ":" + terminfo}, Capabilities{Colours: TrueColour, Attributes: Bold | Underline}},
+		{"COLORTERM overrides terminfo", map[string]string{"TERM": "sunder-8", "TERMINFO": terminfo, "COLORTERM": "truecolor"}, Capabilities{Colours: TrueColour, Attributes: Bold}},
+		{"UTF-8 locale", map[string]string{"TERM": "sunder-unknown", "LANG": "en_GB.UTF-8"}, Capabilities{Colours: Colours16, Attributes: AllAttributes, UTF8: true}},
+		{"LC_ALL takes precedence", map[string]string{"TERM": "sunder-unknown", "LC_ALL": "C", "LANG": "en_GB.utf8"}, Capabilities{Colours: Colours16, Attributes: AllAttributes}},
+		{"LC_CTYPE takes precedence over LANG", map[string]string{"TERM": "sunder-unknown", "LC_CTYPE": "en_US.utf8", "LANG": "C"}, Capabilities{Colours: Colours16, Attributes: AllAttributes, UTF8: true}},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			actual := detect(func(name string) string {
+				return test.env[name]
+			})
+			if actual != test.expected {
+				t.Fatalf("expected %+v, got %+v", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,134 @@
+package capability
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// the parts of a compiled terminfo entry we are interested in
+type terminfo struct {
+	colours    ColourDepth
+	attributes Attribute
+}
+
+const (
+	terminfoMagic         = 0432
+	terminfoExtendedMagic = 01036
+
+	// index of max_colors in the numbers section
+	numberMaxColours = 13
+)
+
+// index in the strings section of the sequence which enables each attribute
+var attributeStrings = map[Attribute]int{
+	Blink:     26, // enter_blink_mode
+	Bold:      27, // enter_bold_mode
+	Dim:       30, // enter_dim_mode
+	Hidden:    32, // enter_secure_mode
+	Inverse:   34, // enter_reverse_mode
+	Underline: 36, // enter_underline_mode
+}
+
+// terminfoDirs returns the directories searched for terminfo entries, in the same order as ncurses
+func terminfoDirs(getenv func(string) string) []string {
+	var dirs []string
+	if dir := getenv("TERMINFO"); dir != "" {
+		dirs = append(dirs, dir)
+	}
+	if home := getenv("HOME"); home != "" {
+		dirs = append(dirs, filepath.Join(home, ".terminfo"))
+	}
+	for _, dir := range strings.Split(getenv("TERMINFO_DIRS"), ":") {
+		if dir != "" {
+			dirs = append(dirs, dir)
+		}
+	}
+	return append(dirs, "/etc/terminfo", "/lib/terminfo", "/usr/share/terminfo", "/usr/lib/terminfo")
+}
+
+func loadTerminfo(term string, getenv func(string) string) (*terminfo, error) {
+	if term == "" || strings.ContainsAny(term, "/\\") {
+		return nil, fmt.Errorf("invalid terminal name '%s'", term)
+	}
+	for _, dir := range terminfoDirs(getenv) {
+		// entries are grouped by their first letter, or its hex value on some systems
+		for _, group := range []string{term[:1], fmt.Sprintf("%x", term[0])} {
+			data, err := os.ReadFile(filepath.Join(dir, group, term))
+			if err == nil {
+				return parseTerminfo(data)
+			}
+		}
+	}
+	return nil, os.ErrNotExist
+}
+
+// parseTerminfo reads a compiled terminfo entry, as described in term(5)
+func parseTerminfo(data []byte) (*terminfo, error) {
+
+	if len(data) < 12 {
+		return nil, fmt.Errorf("terminfo entry is too short")
+	}
+
+	header := make([]int, 6)
+	for i := range header {
+		header[i] = int(int16(binary.LittleEndian.Uint16(data[i*2:])))
+	}
+	magic, namesSize, boolCount, numberCount, stringCount := header[0], header[1], header[2], header[3], header[4]
+
+	numberSize := 2
+	switch magic {
+	case terminfoMagic:
+	case terminfoExtendedMagic:
+		numberSize = 4
+	default:
+		return nil, fmt.Errorf("unknown terminfo format %o", magic)
+	}
+
+	numbersStart := 12 + namesSize + boolCount
+	// the numbers section is aligned to an even byte
+	if numbersStart%2 != 0 {
+		numbersStart++
+	}
+	stringsStart := numbersStart + numberCount*numberSize
+	tableStart := stringsStart + stringCount*2
+	if namesSize < 0 || boolCount < 0 || numberCount < 0 || stringCount < 0 || tableStart > len(data) {
+		return nil, fmt.Errorf("terminfo entry is truncated")
+	}
+
+	info := &terminfo{colours: Monochrome}
+
+	if numberMaxColours < numberCount {
+		offset := numbersStart + numberMaxColours*numberSize
+		var colours int
+		if numberSize == 2 {
+			colours = int(int16(binary.LittleEndian.Uint16(data[offset:])))
+		} else {
+			colours = int(int32(binary.LittleEndian.Uint32(data[offset:])))
+		}
+		switch {
+		case colours >= int(TrueColour):
+			info.colours = TrueColour
+		case colours >= 256:
+			info.colours = Colours256
+		case colours >= 16:
+			info.colours = Colours16
+		case colours >= 8:
+			info.colours = Colours8
+		}
+	}
+
+	for attr, index := range attributeStrings {
+		if index >= stringCount {
+			continue
+		}
+		// a negative offset means the capability is missing
+		if offset := int16(binary.LittleEndian.Uint16(data[stringsStart+index*2:])); offset >= 0 {
+			info.attributes |= attr
+		}
+	}
+
+	return info, nil
+}
@@ -0,0 +1,123 @@
+package capability
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// compileTerminfo builds a compiled terminfo entry with the given numbers, and with the strings at the given indexes
+// present
+func compileTerminfo(extended bool, names string, boolCount int, numbers []int, stringCount int, present ...int) []byte {
+	magic, numberSize := terminfoMagic, 2
+	if extended {
+		magic, numberSize = terminfoExtendedMagic, 4
+	}
+	names += "\x00"
+	table := []byte("\x1b[1m\x00")
+
+	var data []byte
+	for _, value := range []int{magic, len(names), boolCount, len(numbers), stringCount, len(table)} {
+		data = appendUint16(data, uint16(value))
+	}
+	data = append(data, names...)
+	data = append(data, make([]byte, boolCount)...)
+	if len(data)%2 != 0 {
+		data = append(data, 0)
+	}
+	for _, number := range numbers {
+		if numberSize == 2 {
+			data = appendUint16(data, uint16(number))
+		} else {
+			data = append(data, make([]byte, 4)...)
+			binary.LittleEndian.PutUint32(data[len(data)-4:], uint32(number))
+		}
+	}
+	offsets := make([]int16, stringCount)
+	for i := range offsets {
+		offsets[i] = -1
+	}
+	for _, index := range present {
+		offsets[index] = 0
+	}
+	for _, offset := range offsets {
+		data = appendUint16(data, uint16(offset))
+	}
+	return append(data, table...)
+}
+
+// appendUint16 appends a little endian 16 bit number
+func appendUint16(data []byte, value uint16) []byte {
+	return append(data, byte(value), byte(value>>8))
+}
+
+// numbersWithColours returns a numbers section with max_colors set
+func numbersWithColours(colours int) []int {
+	numbers := make([]int, numberMaxColours+1)
+	for i := range numbers {
+		numbers[i] = -1
+	}
+	numbers[numberMaxColours] = colours
+	return numbers
+}
+
+func TestParseTerminfo(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       []byte
+		colours    ColourDepth
+		attributes Attribute
+	}{
+		{"legacy 8 colours", compileTerminfo(false, "test", 2, numbersWithColours(8), 40, 27, 36), Colours8, Bold | Underline},
+		{"legacy 256 colours", compileTerminfo(false, "test-256color", 3, numbersWithColours(256), 40, 26, 27, 30, 32, 34, 36), Colours256, AllAttributes},
+		{"legacy without colours", compileTerminfo(false, "test", 2, numbersWithColours(-1), 40, 34), Monochrome, Inverse},
+		{"legacy 16 colours with odd names", compileTerminfo(false, "test-16", 1, numbersWithColours(16), 40, 30), Colours16, Dim},
+		{"legacy without max_colors", compileTerminfo(false, "test", 2, []int{80}, 40, 27), Monochrome, Bold},
+		{"legacy with few strings", compileTerminfo(false, "test", 2, numbersWithColours(8), 28, 26, 27), Colours8, Bold | Blink},
+		{"extended 256 colours", compileTerminfo(true, "test-256color", 2, numbersWithColours(256), 40, 27), Colours256, Bold},
+		{"extended truecolour", compileTerminfo(true, "test-direct", 2, numbersWithColours(1<<24), 40, 27, 36), TrueColour, Bold | Underline},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			info, err := parseTerminfo(test.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.colours != test.colours {
+				t.Errorf("expected %d colours, got %d", test.colours, info.colours)
+			}
+			if info.attributes != test.attributes {
+				t.Errorf("expected attributes %b, got %b", test.attributes, info.attributes)
+			}
+		})
+	}
+}
+
+func TestParseInvalidTerminfo(t *testing.T) {
+	entry := compileTerminfo(false, "test", 2, numbersWithColours(256), 40, 27)
+	unknown := append([]byte(nil), entry...)
+	binary.LittleEndian.PutUint16(unknown, 0777)
+	negative := append([]byte(nil), entry...)
+	binary.LittleEndian.PutUint16(negative[4:], 0xffff)
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"empty", nil},
+		{"short header", entry[:8]},
+		{"unknown format", unknown},
+		{"truncated names", entry[:14]},
+		{"truncated numbers", entry[:30]},
+		{"truncated strings", entry[:len(entry)-len("\x1b[1m\x00")-2]},
+		{"negative size", negative},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := parseTerminfo(test.data); err == nil {
+				t.Fatal("expected an error")
+			}
+		})
+	}
+}
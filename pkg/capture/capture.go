@@ -0,0 +1,198 @@
+package capture
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/liamg/sunder/pkg/termutil"
+)
+
+type Format uint8
+
+const (
+	// Text is plain text with no formatting
+	Text Format = iota
+	// ANSI is text with SGR escape sequences to preserve colours and attributes
+	ANSI
+	// HTML is a standalone HTML document preserving colours and attributes
+	HTML
+)
+
+// colours used by the HTML output when the terminal default colours are in use
+const (
+	defaultFgCSS = "#e5e5e5"
+	defaultBgCSS = "#000000"
+)
+
+// ParseFormat converts a format name into a Format
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "text", "txt":
+		return Text, nil
+	case "ansi":
+		return ANSI, nil
+	case "html":
+		return HTML, nil
+	}
+	return Text, fmt.Errorf("unknown capture format '%s'", name)
+}
+
+// Extension returns a file extension suitable for captures in this format
+func (f Format) Extension() string {
+	switch f {
+	case ANSI:
+		return "ansi"
+	case HTML:
+		return "html"
+	default:
+		return "txt"
+	}
+}
+
+// Write writes the visible contents of the buffer, preceded by up to scrollback lines of history, to w in the given
+// format
+func Write(w io.Writer, buffer *termutil.Buffer, scrollback int, format Format) error {
+	lines := buffer.GetLines(scrollback)
+	switch format {
+	case Text:
+		return writeText(w, lines)
+	case ANSI:
+		return writeANSI(w, lines)
+	case HTML:
+		return writeHTML(w, lines)
+	}
+	return fmt.Errorf("unsupported capture format")
+}
+
+func writeText(w io.Writer, lines []termutil.Line) error {
+	for _, line := range lines {
+		var output strings.Builder
+		for _, cell := range line.Cells() {
+			output.WriteString(cell.String())
+		}
+		if _, err := fmt.Fprintln(w, strings.TrimRight(output.String(), " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func writeANSI(w io.Writer, lines []termutil.Line) error {
+	var lastAttr termutil.CellAttributes
+	for _, line := range lines {
+		var output strings.Builder
+		for _, cell := range line.Cells() {
+			output.WriteString(cell.Attr().GetHyperlinkDiffANSI(lastAttr))
+			output.WriteString(cell.Attr().GetDiffANSI(lastAttr))
+			output.WriteString(cell.String())
+			lastAttr = cell.Attr()
+		}
+		// links end with the line, so each line of the file can be shown on its own
+		end := lastAttr.WithHyperlink(nil)
+		output.WriteString(end.GetHyperlinkDiffANSI(lastAttr))
+		lastAttr = end
+		if _, err := fmt.Fprintln(w, output.String()); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprint(w, "\x1b[0m")
+	return err
+}
+
+func writeHTML(w io.Writer, lines []termutil.Line) error {
+
+	if _, err := fmt.Fprintf(
+		w,
+		"<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Sunder capture</title>\n</head>\n"+
+			"<body style=\"background: %s;\">\n<pre style=\"color: %s; font-family: monospace;\">\n",
+		defaultBgCSS,
+		defaultFgCSS,
+	); err != nil {
+		return err
+	}
+
+	for _, line := range lines {
+		var output strings.Builder
+		var span strings.Builder
+		var lastStyle string
+		flush := func() {
+			if span.Len() == 0 {
+				return
+			}
+			if lastStyle == "" {
+				output.WriteString(html.EscapeString(span.String()))
+			} else {
+				output.WriteString(fmt.Sprintf("<span style=\"%s\">%s</span>", lastStyle, html.EscapeString(span.String())))
+			}
+			span.Reset()
+		}
+		for _, cell := range line.Cells() {
+			style := cssStyle(cell.Attr())
+			if style != lastStyle {
+				flush()
+				lastStyle = style
+			}
+			span.WriteString(cell.String())
+		}
+		flush()
+		if _, err := fmt.Fprintln(w, output.String()); err != nil {
+			return err
+		}
+	}
+
+	_, err := fmt.Fprint(w, "</pre>\n</body>\n</html>\n")
+	return err
+}
+
+func cssStyle(attr termutil.CellAttributes) string {
+
+	var rules []string
+
+	fg, fgSet := cssColour(attr.FgColour())
+	bg, bgSet := cssColour(attr.BgColour())
+	if attr.Inverse() {
+		if !fgSet {
+			fg = defaultFgCSS
+		}
+		if !bgSet {
+			bg = defaultBgCSS
+		}
+		fg, bg = bg, fg
+		fgSet, bgSet = true, true
+	}
+	if attr.Hidden() {
+		fg = bg
+		if !bgSet {
+			fg = defaultBgCSS
+		}
+		fgSet = true
+	}
+
+	if fgSet {
+		rules = append(rules, "color: "+fg)
+	}
+	if bgSet {
+		rules = append(rules, "background-color: "+bg)
+	}
+	if attr.Bold() {
+		rules = append(rules, "font-weight: bold")
+	}
+	if attr.Dim() {
+		rules = append(rules, "opacity: 0.5")
+	}
+	if attr.Underline() {
+		rules = append(rules, "text-decoration: underline")
+	}
+
+	return strings.Join(rules, "; ")
+}
+
+func cssColour(colour termutil.Colour) (string, bool) {
+	r, g, b, ok := colour.RGB()
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("#%02x%02x%02x", r, g, b), true
+}
@@ -0,0 +1,240 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/liamg/sunder/pkg/capture"
+)
+
+// Config holds user configurable behaviour for sunder
+type Config struct {
+	// Clipboard forwards OSC 52 clipboard writes from panes to the parent terminal
+	Clipboard bool
+	// CaptureFormat is the format used when capturing pane contents
+	CaptureFormat capture.Format
+	// CaptureScrollback is the number of lines of history to include when capturing pane contents
+	CaptureScrollback int
+	// CaptureDir is the directory pane captures are saved to
+	CaptureDir string
+	// PipeCommand is a shell command which receives the output of a pane via stdin when piping is enabled. If it is
+	// empty, pane output is appended to a log file in CaptureDir instead.
+	PipeCommand string
+	// RecordPanes records the raw output of each pane alongside the session when recording is enabled
+	RecordPanes bool
+	// HintPatterns are the regular expressions highlighted in hint mode
+	HintPatterns []*regexp.Regexp
+	// HintOpenCommand is the command used to open a hint, which receives the hint text as its only argument
+	HintOpenCommand string
+	// MonitorActivity flags panes in the status bar when they write output while not active
+	MonitorActivity bool
+	// MonitorSilence flags panes which have not written output for this many seconds. Zero disables it.
+	MonitorSilence int
+	// MonitorBell flags panes which ring the bell while not active, and passes bells on to the parent terminal
+	MonitorBell bool
+	// AlertFlash flashes the screen when a pane is flagged
+	AlertFlash bool
+	// AlertNotify sends a desktop notification to the parent terminal when a pane is flagged
+	AlertNotify bool
+	// Notifications forwards desktop notifications (OSC 9 and OSC 777) from panes to the parent terminal
+	Notifications bool
+	// NotifyCommand is a shell command which is run for each desktop notification from a pane, instead of
+	// forwarding it to the parent terminal
+	NotifyCommand string
+	// Hooks are shell commands run when lifecycle events occur
+	Hooks map[Hook][]string
+	// MaxFPS limits how many frames are drawn per second while panes are producing output. Zero removes the limit.
+	MaxFPS int
+}
+
+// Hook is a lifecycle event which can trigger commands
+type Hook string
+
+const (
+	HookPaneCreated     Hook = "pane-created"
+	HookPaneExited      Hook = "pane-exited"
+	HookFocusChanged    Hook = "focus-changed"
+	HookWindowRenamed   Hook = "window-renamed"
+	HookSessionAttached Hook = "session-attached"
+	HookSessionDetached Hook = "session-detached"
+	HookResize          Hook = "resize"
+)
+
+var hooks = []Hook{
+	HookPaneCreated,
+	HookPaneExited,
+	HookFocusChanged,
+	HookWindowRenamed,
+	HookSessionAttached,
+	HookSessionDetached,
+	HookResize,
+}
+
+// defaultHintPatterns match URLs, file:line paths, UUIDs, IP addresses and git hashes
+var defaultHintPatterns = []string{
+	`(?:https?|ftp|file)://[^\s<>"'` + "`" + `]*[^\s<>"'` + "`" + `.,;:!?)\]}]`,
+	`(?:[\w.~-]*/)*[\w.-]+\.[A-Za-z]\w*:\d+(?::\d+)?`,
+	`\b[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}\b`,
+	`\b(?:\d{1,3}\.){3}\d{1,3}(?::\d+)?\b`,
+	`\b[0-9a-f]{7,40}\b`,
+}
+
+// Default returns the configuration used when no config file is present
+func Default() *Config {
+	cfg := &Config{
+		Clipboard:       true,
+		CaptureFormat:   capture.Text,
+		CaptureDir:      expandPath("~"),
+		HintOpenCommand: "xdg-open",
+		MonitorBell:     true,
+		Notifications:   true,
+		Hooks:           make(map[Hook][]string),
+		MaxFPS:          60,
+	}
+	if runtime.GOOS == "darwin" {
+		cfg.HintOpenCommand = "open"
+	}
+	for _, pattern := range defaultHintPatterns {
+		cfg.HintPatterns = append(cfg.HintPatterns, regexp.MustCompile(pattern))
+	}
+	return cfg
+}
+
+// DefaultPath returns the location of the user's config file
+func DefaultPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "sunder", "config")
+}
+
+// Load reads the config file at the given path. Each line takes the form "key = value", and lines starting with
+// a # are ignored. If the file does not exist, the default configuration is returned.
+func Load(path string) (*Config, error) {
+
+	cfg := Default()
+
+	if path == "" {
+		return cfg, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return cfg, nil
+		}
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	scanner := bufio.NewScanner(f)
+	var lineNumber int
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected key = value", path, lineNumber)
+		}
+		if err := cfg.set(strings.TrimSpace(parts[0]), strings.TrimSpace(parts[1])); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, lineNumber, err)
+		}
+	}
+
+	return cfg, scanner.Err()
+}
+
+func (c *Config) set(key string, value string) error {
+	if strings.HasPrefix(key, "hook-") {
+		return c.addHook(Hook(strings.TrimPrefix(key, "hook-")), value)
+	}
+	var err error
+	switch key {
+	case "clipboard":
+		c.Clipboard, err = parseBool(value)
+	case "capture-format":
+		c.CaptureFormat, err = capture.ParseFormat(value)
+	case "capture-scrollback":
+		c.CaptureScrollback, err = strconv.Atoi(value)
+	case "capture-dir":
+		c.CaptureDir = expandPath(value)
+	case "pipe-command":
+		c.PipeCommand = value
+	case "record-panes":
+		c.RecordPanes, err = parseBool(value)
+	case "hint-pattern":
+		// patterns are added to the defaults rather than replacing them
+		var expr *regexp.Regexp
+		if expr, err = regexp.Compile(value); err == nil {
+			c.HintPatterns = append(c.HintPatterns, expr)
+		}
+	case "hint-open-command":
+		c.HintOpenCommand = value
+	case "monitor-activity":
+		c.MonitorActivity, err = parseBool(value)
+	case "monitor-silence":
+		c.MonitorSilence, err = strconv.Atoi(value)
+	case "monitor-bell":
+		c.MonitorBell, err = parseBool(value)
+	case "alert-flash":
+		c.AlertFlash, err = parseBool(value)
+	case "alert-notify":
+		c.AlertNotify, err = parseBool(value)
+	case "notifications":
+		c.Notifications, err = parseBool(value)
+	case "notify-command":
+		c.NotifyCommand = value
+	case "max-fps":
+		c.MaxFPS, err = strconv.Atoi(value)
+	default:
+		return fmt.Errorf("unknown key '%s'", key)
+	}
+	return err
+}
+
+// addHook adds a command to run for a lifecycle event. Each event can have several commands.
+func (c *Config) addHook(hook Hook, command string) error {
+	for _, known := range hooks {
+		if known == hook {
+			c.Hooks[hook] = append(c.Hooks[hook], command)
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown hook '%s'", hook)
+}
+
+func parseBool(value string) (bool, error) {
+	switch strings.ToLower(value) {
+	case "on", "true", "yes", "1":
+		return true, nil
+	case "off", "false", "no", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid boolean value '%s'", value)
+}
+
+// expandPath replaces a leading ~ with the user's home directory
+func expandPath(path string) string {
+	if path != "~" && !strings.HasPrefix(path, "~/") {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(home, path[1:])
+}
@@ -0,0 +1,242 @@
+package input
+
+import (
+	"bytes"
+	"fmt"
+	"unicode"
+)
+
+// KeyModes are the terminal modes which change the sequences sent for keys
+type KeyModes struct {
+	// ApplicationCursorKeys (DECCKM) sends unmodified cursor keys as SS3 rather than CSI sequences
+	ApplicationCursorKeys bool
+	// ApplicationKeypad (DECKPAM) sends keypad keys as SS3 sequences rather than the characters on them
+	ApplicationKeypad bool
+	// KeyboardFlags are the enhancements of the kitty keyboard protocol which are enabled. Only disambiguating keys
+	// (flag 1), which sends keys that have no unique legacy encoding as CSI code ; modifiers u, is supported.
+	KeyboardFlags int
+	// ModifyOtherKeys is the xterm modifyOtherKeys level, which sends modified keys as CSI 27 ; modifiers ; code ~
+	ModifyOtherKeys int
+}
+
+// KeyboardDisambiguate is the kitty keyboard protocol flag for sending ambiguous keys as CSI u sequences
+const KeyboardDisambiguate = 1
+
+var cursorKeyFinals = map[KeyCode]byte{
+	KeyUp:    'A',
+	KeyDown:  'B',
+	KeyRight: 'C',
+	KeyLeft:  'D',
+	KeyHome:  'H',
+	KeyEnd:   'F',
+}
+
+// tildeKeyNumbers are the parameters of the keys sent as CSI number ~
+var tildeKeyNumbers = map[KeyCode]int{
+	KeyInsert:   2,
+	KeyDelete:   3,
+	KeyPageUp:   5,
+	KeyPageDown: 6,
+}
+
+var (
+	pasteStart = []byte("\x1b[200~")
+	pasteEnd   = []byte("\x1b[201~")
+)
+
+// Encode returns the sequence a terminal in the given modes sends for a cursor or keypad key. It returns false for
+// other keys, which are sent the same way whatever the mode.
+func Encode(key Key, modes KeyModes) ([]byte, bool) {
+	if key.Keypad {
+		if key.Modifiers != 0 {
+			return nil, false
+		}
+		for final, r := range keypadKeys {
+			if (key.Code == KeyEnter && r == '\r') || (key.Code == KeyRune && r == key.Rune) {
+				if modes.ApplicationKeypad {
+					return []byte{0x1b, 'O', final}, true
+				}
+				return []byte(string(r)), true
+			}
+		}
+		return nil, false
+	}
+	final, ok := cursorKeyFinals[key.Code]
+	if !ok {
+		return nil, false
+	}
+	if key.Modifiers != 0 {
+		return []byte(fmt.Sprintf("\x1b[1;%d%c", encodeModifiers(key.Modifiers), final)), true
+	}
+	if modes.ApplicationCursorKeys {
+		return []byte{0x1b, 'O', final}, true
+	}
+	return []byte{0x1b, '[', final}, true
+}
+
+// EncodeExtended returns the sequence a terminal in the given modes sends for a key, which was read using the kitty
+// keyboard protocol or modifyOtherKeys. Programs which haven't asked for either get the closest legacy encoding.
+// Nothing is returned for unknown keys.
+func EncodeExtended(key Key, modes KeyModes) []byte {
+	if key.Code == KeyUnknown {
+		return nil
+	}
+	code, ok := keyCodepoint(key)
+	if !ok {
+		if encoded, ok := Encode(key, modes); ok {
+			return encoded
+		}
+		return encodeLegacy(key)
+	}
+	// keys which are sent as text, or whose legacy encoding isn't ambiguous, are sent the same way in every mode
+	plain := key.Modifiers&^ModShift == 0 && (key.Code == KeyRune || key.Modifiers == 0)
+	switch {
+	case plain && key.Code != KeyEscape:
+	case modes.KeyboardFlags&KeyboardDisambiguate != 0:
+		if key.Modifiers == 0 {
+			return []byte(fmt.Sprintf("\x1b[%du", code))
+		}
+		return []byte(fmt.Sprintf("\x1b[%d;%du", code, encodeModifiers(key.Modifiers)))
+	case modes.ModifyOtherKeys > 0 && key.Modifiers != 0:
+		return []byte(fmt.Sprintf("\x1b[27;%d;%d~", encodeModifiers(key.Modifiers), code))
+	}
+	return encodeLegacy(key)
+}
+
+// keyCodepoint returns the unicode codepoint used to identify a key in the kitty keyboard protocol and
+// modifyOtherKeys
+func keyCodepoint(key Key) (rune, bool) {
+	switch key.Code {
+	case KeyRune:
+		return key.Rune, !key.Keypad
+	case KeyEnter:
+		return '\r', !key.Keypad
+	case KeyTab:
+		return '\t', true
+	case KeyBackspace:
+		return 0x7f, true
+	case KeyEscape:
+		return 0x1b, true
+	}
+	return 0, false
+}
+
+// encodeLegacy returns the bytes a terminal sends for a key without any keyboard enhancements. Some modified keys
+// can't be told apart from others this way, e.g. ctrl + enter is sent as enter.
+func encodeLegacy(key Key) []byte {
+	if number, ok := tildeKeyNumbers[key.Code]; ok {
+		if key.Modifiers != 0 {
+			return []byte(fmt.Sprintf("\x1b[%d;%d~", number, encodeModifiers(key.Modifiers)))
+		}
+		return []byte(fmt.Sprintf("\x1b[%d~", number))
+	}
+	var encoded []byte
+	switch key.Code {
+	case KeyEnter:
+		encoded = []byte{'\r'}
+	case KeyTab:
+		if key.Modifiers&ModShift != 0 {
+			encoded = []byte("\x1b[Z")
+		} else {
+			encoded = []byte{'\t'}
+		}
+	case KeyBackspace:
+		if key.Modifiers&ModCtrl != 0 {
+			encoded = []byte{0x08}
+		} else {
+			encoded = []byte{0x7f}
+		}
+	case KeyEscape:
+		encoded = []byte{0x1b}
+	default:
+		r := key.Rune
+		if key.Modifiers&ModShift != 0 {
+			r = unicode.ToUpper(r)
+		}
+		if key.Modifiers&ModCtrl != 0 {
+			switch {
+			case r == ' ' || r == '@':
+				r = 0
+			case r == '?':
+				r = 0x7f
+			case unicode.ToUpper(r) >= 'A' && unicode.ToUpper(r) <= '_':
+				r = unicode.ToUpper(r) & 0x1f
+			}
+		}
+		encoded = []byte(string(r))
+	}
+	if key.Modifiers&ModAlt != 0 {
+		encoded = append([]byte{0x1b}, encoded...)
+	}
+	return encoded
+}
+
+// encodeModifiers converts modifiers into an xterm modifier parameter (1 + bitmask)
+func encodeModifiers(mods Modifier) int {
+	var mask int
+	if mods&ModShift != 0 {
+		mask |= 1
+	}
+	if mods&ModAlt != 0 {
+		mask |= 2
+	}
+	if mods&ModCtrl != 0 {
+		mask |= 4
+	}
+	return mask + 1
+}
+
+// Encoder rewrites input read from a terminal for a program which may have chosen different key modes. The state of
+// bracketed pastes is kept between calls, so pasted text is never rewritten.
+type Encoder struct {
+	pasting bool
+}
+
+// Encode rewrites the cursor and keypad keys in data into the sequences a terminal in the given modes would send.
+// Everything else, including sequences which are incomplete or not recognised, is passed on as it was read.
+func (e *Encoder) Encode(data []byte, modes KeyModes) []byte {
+	output := make([]byte, 0, len(data))
+	for len(data) > 0 {
+		if e.pasting {
+			end := bytes.Index(data, pasteEnd)
+			if end < 0 {
+				return append(output, data...)
+			}
+			end += len(pasteEnd)
+			output = append(output, data[:end]...)
+			data = data[end:]
+			e.pasting = false
+			continue
+		}
+		if bytes.HasPrefix(data, pasteStart) {
+			e.pasting = true
+			output = append(output, pasteStart...)
+			data = data[len(pasteStart):]
+			continue
+		}
+		key, size := ParseKey(data)
+		raw := data[:size]
+		data = data[size:]
+		if isExtended(raw) {
+			output = append(output, EncodeExtended(key, modes)...)
+			continue
+		}
+		// only rewrite keys sent as a sequence of their own, leaving tilde keys and alt + key as they are
+		if len(raw) > 2 && raw[0] == 0x1b && (raw[1] == '[' || raw[1] == 'O') && raw[len(raw)-1] != '~' {
+			if encoded, ok := Encode(key, modes); ok {
+				output = append(output, encoded...)
+				continue
+			}
+		}
+		output = append(output, raw...)
+	}
+	return output
+}
+
+// isExtended returns true if raw is a key sent using the kitty keyboard protocol or modifyOtherKeys
+func isExtended(raw []byte) bool {
+	if len(raw) < 4 || raw[0] != 0x1b || raw[1] != '[' {
+		return false
+	}
+	return raw[len(raw)-1] == 'u' || (raw[len(raw)-1] == '~' && bytes.HasPrefix(raw, []byte("\x1b[27;")))
+}
@@ -0,0 +1,147 @@
+package input
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected Key
+		size     int
+	}{
+		{"character", "a", Key{Code: KeyRune, Rune: 'a'}, 1},
+		{"multi-byte character", "日本", Key{Code: KeyRune, Rune: '日'}, 3},
+		{"enter", "\r", Key{Code: KeyEnter}, 1},
+		{"tab", "\t", Key{Code: KeyTab}, 1},
+		{"backspace", "\x7f", Key{Code: KeyBackspace}, 1},
+		{"ctrl + letter", "\x01", Key{Code: KeyRune, Rune: 'a', Modifiers: ModCtrl}, 1},
+		{"ctrl + space", "\x00", Key{Code: KeyRune, Rune: ' ', Modifiers: ModCtrl}, 1},
+		{"escape", "\x1b", Key{Code: KeyEscape}, 1},
+		{"escape followed by escape", "\x1b\x1b", Key{Code: KeyEscape}, 1},
+		{"alt + letter", "\x1ba", Key{Code: KeyRune, Rune: 'a', Modifiers: ModAlt}, 2},
+		{"alt + ctrl + letter", "\x1b\x01", Key{Code: KeyRune, Rune: 'a', Modifiers: ModAlt | ModCtrl}, 2},
+		{"cursor key", "\x1b[A", Key{Code: KeyUp}, 3},
+		{"application cursor key", "\x1bOD", Key{Code: KeyLeft}, 3},
+		{"modified cursor key", "\x1b[1;5C", Key{Code: KeyRight, Modifiers: ModCtrl}, 6},
+		{"tilde key", "\x1b[5~", Key{Code: KeyPageUp}, 4},
+		{"modified tilde key", "\x1b[3;2~", Key{Code: KeyDelete, Modifiers: ModShift}, 6},
+		{"vt220 home", "\x1b[1~", Key{Code: KeyHome}, 4},
+		{"rxvt end", "\x1b[8~", Key{Code: KeyEnd}, 4},
+		{"incomplete sequence is alt + [", "\x1b[1;5", Key{Code: KeyRune, Rune: '[', Modifiers: ModAlt}, 2},
+		{"unknown tilde key is alt + [", "\x1b[99~", Key{Code: KeyRune, Rune: '[', Modifiers: ModAlt}, 2},
+		{"kitty key", "\x1b[97u", Key{Code: KeyRune, Rune: 'a'}, 5},
+		{"kitty ctrl + letter", "\x1b[97;5u", Key{Code: KeyRune, Rune: 'a', Modifiers: ModCtrl}, 7},
+		{"kitty ctrl + shift + letter", "\x1b[97;6u", Key{Code: KeyRune, Rune: 'a', Modifiers: ModCtrl | ModShift}, 7},
+		{"kitty alternate keys are ignored", "\x1b[97:65;2u", Key{Code: KeyRune, Rune: 'a', Modifiers: ModShift}, 10},
+		{"kitty ctrl + enter", "\x1b[13;5u", Key{Code: KeyEnter, Modifiers: ModCtrl}, 7},
+		{"kitty escape", "\x1b[27u", Key{Code: KeyEscape}, 5},
+		{"kitty shift + tab", "\x1b[9;2u", Key{Code: KeyTab, Modifiers: ModShift}, 6},
+		{"kitty ctrl + backspace", "\x1b[127;5u", Key{Code: KeyBackspace, Modifiers: ModCtrl}, 8},
+		{"kitty keypad digit", "\x1b[57404u", Key{Code: KeyRune, Rune: '5', Keypad: true}, 8},
+		{"kitty keypad operator", "\x1b[57413u", Key{Code: KeyRune, Rune: '+', Keypad: true}, 8},
+		{"kitty keypad enter", "\x1b[57414;5u", Key{Code: KeyEnter, Keypad: true, Modifiers: ModCtrl}, 10},
+		{"kitty keypad cursor key", "\x1b[57417u", Key{Code: KeyLeft}, 8},
+		{"kitty keypad page down", "\x1b[57422;2u", Key{Code: KeyPageDown, Modifiers: ModShift}, 10},
+		{"kitty keypad delete", "\x1b[57426u", Key{Code: KeyDelete}, 8},
+		{"kitty media key", "\x1b[57428u", Key{Code: KeyUnknown}, 8},
+		{"kitty key without a code is alt + [", "\x1b[u", Key{Code: KeyRune, Rune: '[', Modifiers: ModAlt}, 2},
+		{"modifyOtherKeys ctrl + letter", "\x1b[27;5;97~", Key{Code: KeyRune, Rune: 'a', Modifiers: ModCtrl}, 10},
+		{"modifyOtherKeys shift + enter", "\x1b[27;2;13~", Key{Code: KeyEnter, Modifiers: ModShift}, 10},
+		{"keypad digit", "\x1bOu", Key{Code: KeyRune, Rune: '5', Keypad: true}, 3},
+		{"keypad enter", "\x1bOM", Key{Code: KeyEnter, Keypad: true}, 3},
+		{"keypad equals", "\x1bOX", Key{Code: KeyRune, Rune: '=', Keypad: true}, 3},
+		{"keypad final bytes only count after SS3", "\x1b[q", Key{Code: KeyRune, Rune: '[', Modifiers: ModAlt}, 2},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			key, size := ParseKey([]byte(test.input))
+			if key != test.expected || size != test.size {
+				t.Errorf("expected %+v (%d bytes), got %+v (%d bytes)", test.expected, test.size, key, size)
+			}
+		})
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		name     string
+		key      Key
+		modes    KeyModes
+		expected string
+		ok       bool
+	}{
+		{"cursor key", Key{Code: KeyUp}, KeyModes{}, "\x1b[A", true},
+		{"application cursor key", Key{Code: KeyUp}, KeyModes{ApplicationCursorKeys: true}, "\x1bOA", true},
+		{"modified cursor key", Key{Code: KeyLeft, Modifiers: ModCtrl | ModShift}, KeyModes{ApplicationCursorKeys: true}, "\x1b[1;6D", true},
+		{"home", Key{Code: KeyHome}, KeyModes{}, "\x1b[H", true},
+		{"keypad digit", Key{Code: KeyRune, Rune: '5', Keypad: true}, KeyModes{}, "5", true},
+		{"application keypad digit", Key{Code: KeyRune, Rune: '5', Keypad: true}, KeyModes{ApplicationKeypad: true}, "\x1bOu", true},
+		{"keypad enter", Key{Code: KeyEnter, Keypad: true}, KeyModes{}, "\r", true},
+		{"application keypad enter", Key{Code: KeyEnter, Keypad: true}, KeyModes{ApplicationKeypad: true}, "\x1bOM", true},
+		{"application keypad operator", Key{Code: KeyRune, Rune: '*', Keypad: true}, KeyModes{ApplicationKeypad: true}, "\x1bOj", true},
+		{"modified keypad key", Key{Code: KeyRune, Rune: '5', Keypad: true, Modifiers: ModCtrl}, KeyModes{ApplicationKeypad: true}, "", false},
+		{"character", Key{Code: KeyRune, Rune: 'a'}, KeyModes{}, "", false},
+		{"tilde key", Key{Code: KeyPageUp}, KeyModes{ApplicationCursorKeys: true}, "", false},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			encoded, ok := Encode(test.key, test.modes)
+			if string(encoded) != test.expected || ok != test.ok {
+				t.Errorf("expected %q (%t), got %q (%t)", test.expected, test.ok, encoded, ok)
+			}
+		})
+	}
+}
+
+func TestEncodeExtended(t *testing.T) {
+	kitty := KeyModes{KeyboardFlags: KeyboardDisambiguate}
+	modifyOtherKeys := KeyModes{ModifyOtherKeys: 2}
+
+	tests := []struct {
+		name     string
+		key      Key
+		modes    KeyModes
+		expected string
+	}{
+		{"character", Key{Code: KeyRune, Rune: 'a'}, kitty, "a"},
+		{"shift + letter is text", Key{Code: KeyRune, Rune: 'a', Modifiers: ModShift}, kitty, "A"},
+		{"kitty ctrl + letter", Key{Code: KeyRune, Rune: 'a', Modifiers: ModCtrl}, kitty, "\x1b[97;5u"},
+		{"kitty alt + letter", Key{Code: KeyRune, Rune: 'a', Modifiers: ModAlt}, kitty, "\x1b[97;3u"},
+		{"kitty ctrl + enter", Key{Code: KeyEnter, Modifiers: ModCtrl}, kitty, "\x1b[13;5u"},
+		{"kitty escape", Key{Code: KeyEscape}, kitty, "\x1b[27u"},
+		{"kitty shift + tab", Key{Code: KeyTab, Modifiers: ModShift}, kitty, "\x1b[9;2u"},
+		{"kitty enter", Key{Code: KeyEnter}, kitty, "\r"},
+		{"modifyOtherKeys ctrl + letter", Key{Code: KeyRune, Rune: 'a', Modifiers: ModCtrl}, modifyOtherKeys, "\x1b[27;5;97~"},
+		{"modifyOtherKeys shift + enter", Key{Code: KeyEnter, Modifiers: ModShift}, modifyOtherKeys, "\x1b[27;2;13~"},
+		{"modifyOtherKeys unmodified escape", Key{Code: KeyEscape}, modifyOtherKeys, "\x1b"},
+		{"legacy ctrl + letter", Key{Code: KeyRune, Rune: 'a', Modifiers: ModCtrl}, KeyModes{}, "\x01"},
+		{"legacy ctrl + shift + letter", Key{Code: KeyRune, Rune: 'a', Modifiers: ModCtrl | ModShift}, KeyModes{}, "\x01"},
+		{"legacy ctrl + space", Key{Code: KeyRune, Rune: ' ', Modifiers: ModCtrl}, KeyModes{}, "\x00"},
+		{"legacy ctrl + question mark", Key{Code: KeyRune, Rune: '?', Modifiers: ModCtrl}, KeyModes{}, "\x7f"},
+		{"legacy alt + letter", Key{Code: KeyRune, Rune: 'a', Modifiers: ModAlt}, KeyModes{}, "\x1ba"},
+		{"legacy ctrl + enter", Key{Code: KeyEnter, Modifiers: ModCtrl}, KeyModes{}, "\r"},
+		{"legacy shift + tab", Key{Code: KeyTab, Modifiers: ModShift}, KeyModes{}, "\x1b[Z"},
+		{"legacy ctrl + backspace", Key{Code: KeyBackspace, Modifiers: ModCtrl}, KeyModes{}, "\x08"},
+		{"legacy escape", Key{Code: KeyEscape}, KeyModes{}, "\x1b"},
+		{"legacy modified tilde key", Key{Code: KeyDelete, Modifiers: ModShift}, kitty, "\x1b[3;2~"},
+		{"cursor key", Key{Code: KeyLeft}, KeyModes{ApplicationCursorKeys: true}, "\x1bOD"},
+		{"keypad digit", Key{Code: KeyRune, Rune: '5', Keypad: true}, kitty, "5"},
+		{"application keypad digit", Key{Code: KeyRune, Rune: '5', Keypad: true}, KeyModes{ApplicationKeypad: true}, "\x1bOu"},
+		{"application keypad enter", Key{Code: KeyEnter, Keypad: true}, KeyModes{ApplicationKeypad: true}, "\x1bOM"},
+		{"modified keypad digit", Key{Code: KeyRune, Rune: '5', Keypad: true, Modifiers: ModCtrl}, KeyModes{ApplicationKeypad: true}, "5"},
+		{"unknown key", Key{Code: KeyUnknown}, kitty, ""},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			if encoded := EncodeExtended(test.key, test.modes); !bytes.Equal(encoded, []byte(test.expected)) {
+				t.Errorf("expected %q, got %q", test.expected, encoded)
+			}
+		})
+	}
+}
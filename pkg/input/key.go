@@ -0,0 +1,281 @@
+package input
+
+import "unicode/utf8"
+
+type KeyCode uint8
+
+const (
+	KeyRune KeyCode = iota
+	KeyEscape
+	KeyEnter
+	KeyTab
+	KeyBackspace
+	KeyUp
+	KeyDown
+	KeyRight
+	KeyLeft
+	KeyHome
+	KeyEnd
+	KeyInsert
+	KeyDelete
+	KeyPageUp
+	KeyPageDown
+	// KeyUnknown is a key which can't be passed on to programs, such as a media key sent with the kitty keyboard
+	// protocol. It is dropped.
+	KeyUnknown
+)
+
+type Modifier uint8
+
+const (
+	ModShift Modifier = 1 << iota
+	ModAlt
+	ModCtrl
+)
+
+// Key is a single key press read from the terminal
+type Key struct {
+	Code      KeyCode
+	Rune      rune
+	Modifiers Modifier
+	// Keypad is true for keys on the numeric keypad, sent by terminals in application keypad mode or with the kitty
+	// keyboard protocol. They are otherwise reported like the matching keys on the main keyboard, e.g. a KeyRune of
+	// '5' or KeyEnter.
+	Keypad bool
+}
+
+// IsCtrl returns true if the key is the given letter pressed with ctrl, e.g. IsCtrl('u') for ctrl-u
+func (k Key) IsCtrl(letter rune) bool {
+	return k.Code == KeyRune && k.Modifiers&ModCtrl != 0 && k.Rune == letter
+}
+
+var finalKeyCodes = map[byte]KeyCode{
+	'A': KeyUp,
+	'B': KeyDown,
+	'C': KeyRight,
+	'D': KeyLeft,
+	'H': KeyHome,
+	'F': KeyEnd,
+}
+
+// keypadKeys are the keys on the numeric keypad, by the final byte of their SS3 sequence
+var keypadKeys = map[byte]rune{
+	'j': '*',
+	'k': '+',
+	'l': ',',
+	'm': '-',
+	'n': '.',
+	'o': '/',
+	'p': '0',
+	'q': '1',
+	'r': '2',
+	's': '3',
+	't': '4',
+	'u': '5',
+	'v': '6',
+	'w': '7',
+	'x': '8',
+	'y': '9',
+	'X': '=',
+	'M': '\r',
+}
+
+// the kitty keyboard protocol sends keys which have no unicode codepoint using codes in this private use area
+const (
+	kittyPrivateUseStart = 57344
+	kittyPrivateUseEnd   = 63743
+)
+
+// kittyKeys are the keys in the kitty private use area which have a legacy encoding, e.g. KP_5 and KP_LEFT
+var kittyKeys = map[rune]Key{
+	57399: {Code: KeyRune, Rune: '0', Keypad: true},
+	57400: {Code: KeyRune, Rune: '1', Keypad: true},
+	57401: {Code: KeyRune, Rune: '2', Keypad: true},
+	57402: {Code: KeyRune, Rune: '3', Keypad: true},
+	57403: {Code: KeyRune, Rune: '4', Keypad: true},
+	57404: {Code: KeyRune, Rune: '5', Keypad: true},
+	57405: {Code: KeyRune, Rune: '6', Keypad: true},
+	57406: {Code: KeyRune, Rune: '7', Keypad: true},
+	57407: {Code: KeyRune, Rune: '8', Keypad: true},
+	57408: {Code: KeyRune, Rune: '9', Keypad: true},
+	57409: {Code: KeyRune, Rune: '.', Keypad: true},
+	57410: {Code: KeyRune, Rune: '/', Keypad: true},
+	57411: {Code: KeyRune, Rune: '*', Keypad: true},
+	57412: {Code: KeyRune, Rune: '-', Keypad: true},
+	57413: {Code: KeyRune, Rune: '+', Keypad: true},
+	57414: {Code: KeyEnter, Keypad: true},
+	57415: {Code: KeyRune, Rune: '=', Keypad: true},
+	57416: {Code: KeyRune, Rune: ',', Keypad: true},
+	57417: {Code: KeyLeft},
+	57418: {Code: KeyRight},
+	57419: {Code: KeyUp},
+	57420: {Code: KeyDown},
+	57421: {Code: KeyPageUp},
+	57422: {Code: KeyPageDown},
+	57423: {Code: KeyHome},
+	57424: {Code: KeyEnd},
+	57425: {Code: KeyInsert},
+	57426: {Code: KeyDelete},
+}
+
+var tildeKeyCodes = map[int]KeyCode{
+	1: KeyHome,
+	2: KeyInsert,
+	3: KeyDelete,
+	4: KeyEnd,
+	5: KeyPageUp,
+	6: KeyPageDown,
+	7: KeyHome,
+	8: KeyEnd,
+}
+
+// ParseKey reads a single key press from the start of data, returning the key and the number of bytes it used
+func ParseKey(data []byte) (Key, int) {
+
+	if len(data) == 0 {
+		return Key{}, 0
+	}
+
+	switch b := data[0]; {
+	case b == 0x1b:
+		if len(data) == 1 {
+			return Key{Code: KeyEscape}, 1
+		}
+		switch data[1] {
+		case '[', 'O':
+			if key, size, ok := parseSequence(data); ok {
+				return key, size
+			}
+		}
+		// alt + key is sent as escape followed by the key
+		key, size := ParseKey(data[1:])
+		if key.Code == KeyEscape {
+			return Key{Code: KeyEscape}, 1
+		}
+		key.Modifiers |= ModAlt
+		return key, size + 1
+	case b == '\r', b == '\n':
+		return Key{Code: KeyEnter}, 1
+	case b == '\t':
+		return Key{Code: KeyTab}, 1
+	case b == 0x7f, b == 0x08:
+		return Key{Code: KeyBackspace}, 1
+	case b == 0:
+		return Key{Code: KeyRune, Rune: ' ', Modifiers: ModCtrl}, 1
+	case b < 0x20:
+		return Key{Code: KeyRune, Rune: rune(b) + 'a' - 1, Modifiers: ModCtrl}, 1
+	}
+
+	r, size := utf8.DecodeRune(data)
+	return Key{Code: KeyRune, Rune: r}, size
+}
+
+// parseSequence parses CSI and SS3 encoded keys, e.g. ESC [ A, ESC O A, ESC [ 1 ; 5 A, ESC [ 5 ~ or ESC [ 97 ; 5 u
+func parseSequence(data []byte) (Key, int, bool) {
+	var params []int
+	var current int
+	var hasCurrent, subParam bool
+	for i := 2; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case b >= '0' && b <= '9':
+			if subParam {
+				continue
+			}
+			current = current*10 + int(b-'0')
+			hasCurrent = true
+		case b == ';':
+			params = append(params, current)
+			current, hasCurrent, subParam = 0, false, false
+		case b == ':':
+			// sub-parameters, e.g. alternate keys in the kitty keyboard protocol, are ignored
+			subParam = true
+		case b >= 0x40 && b <= 0x7e:
+			if hasCurrent {
+				params = append(params, current)
+			}
+			var key Key
+			if (b == 'u' && data[1] == '[') || (b == '~' && len(params) > 2 && params[0] == 27) {
+				// the kitty keyboard protocol sends CSI code ; modifiers u, and modifyOtherKeys sends
+				// CSI 27 ; modifiers ; code ~
+				code := 0
+				if b == '~' {
+					code = params[2]
+				} else if len(params) > 0 {
+					code = params[0]
+				}
+				if code <= 0 || code > utf8.MaxRune {
+					return Key{}, 0, false
+				}
+				key = codepointKey(rune(code))
+			} else if b == '~' {
+				if len(params) == 0 {
+					return Key{}, 0, false
+				}
+				code, ok := tildeKeyCodes[params[0]]
+				if !ok {
+					return Key{}, 0, false
+				}
+				key.Code = code
+			} else if r, ok := keypadKeys[b]; ok && data[1] == 'O' {
+				key = Key{Code: KeyRune, Rune: r, Keypad: true}
+				if r == '\r' {
+					key = Key{Code: KeyEnter, Keypad: true}
+				}
+			} else {
+				code, ok := finalKeyCodes[b]
+				if !ok {
+					return Key{}, 0, false
+				}
+				key.Code = code
+			}
+			if len(params) > 1 {
+				key.Modifiers = decodeModifiers(params[1])
+			}
+			return key, i + 1, true
+		default:
+			return Key{}, 0, false
+		}
+	}
+	return Key{}, 0, false
+}
+
+// codepointKey returns the key for a unicode codepoint reported by the kitty keyboard protocol or modifyOtherKeys
+func codepointKey(code rune) Key {
+	switch code {
+	case '\r':
+		return Key{Code: KeyEnter}
+	case '\t':
+		return Key{Code: KeyTab}
+	case 0x7f, 0x08:
+		return Key{Code: KeyBackspace}
+	case 0x1b:
+		return Key{Code: KeyEscape}
+	}
+	if key, ok := kittyKeys[code]; ok {
+		return key
+	}
+	if code >= kittyPrivateUseStart && code <= kittyPrivateUseEnd {
+		return Key{Code: KeyUnknown}
+	}
+	return Key{Code: KeyRune, Rune: code}
+}
+
+// decodeModifiers converts an xterm modifier parameter (1 + bitmask) into modifiers
+func decodeModifiers(param int) Modifier {
+	if param < 1 {
+		return 0
+	}
+	mask := param - 1
+	var mods Modifier
+	if mask&1 != 0 {
+		mods |= ModShift
+	}
+	if mask&2 != 0 {
+		mods |= ModAlt
+	}
+	if mask&4 != 0 {
+		mods |= ModCtrl
+	}
+	return mods
+}
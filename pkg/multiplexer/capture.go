@@ -0,0 +1,74 @@
+package multiplexer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/liamg/sunder/pkg/capture"
+	"github.com/liamg/sunder/pkg/pane"
+)
+
+// CapturePane writes the contents of the active pane, preceded by up to scrollback lines of history, to w
+func (m *Multiplexer) CapturePane(w io.Writer, scrollback int, format capture.Format) error {
+	active, ok := m.rootPane.FindActive().(*pane.TerminalPane)
+	if !ok {
+		return fmt.Errorf("no active terminal pane found")
+	}
+	terminal := active.Terminal()
+	terminal.Lock()
+	defer terminal.Unlock()
+	buffer := terminal.GetActiveBuffer()
+	if buffer == nil {
+		return fmt.Errorf("terminal has no active buffer")
+	}
+	return capture.Write(w, buffer, scrollback, format)
+}
+
+// CapturePaneToBuffer captures the active pane as plain text into the paste buffer
+func (m *Multiplexer) CapturePaneToBuffer() error {
+	var output bytes.Buffer
+	if err := m.CapturePane(&output, m.config.CaptureScrollback, capture.Text); err != nil {
+		return err
+	}
+	m.pasteBuffer = output.Bytes()
+	return nil
+}
+
+// CapturePaneToFile captures the active pane into a new file in the configured capture directory
+func (m *Multiplexer) CapturePaneToFile() (string, error) {
+	format := m.config.CaptureFormat
+	path := filepath.Join(
+		m.config.CaptureDir,
+		fmt.Sprintf("sunder-%s.%s", time.Now().Format("20060102-150405"), format.Extension()),
+	)
+	f, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = f.Close() }()
+	return path, m.CapturePane(f, m.config.CaptureScrollback, format)
+}
+
+// Paste writes the contents of the paste buffer to the active pane
+func (m *Multiplexer) Paste() error {
+	active, ok := m.rootPane.FindActive().(*pane.TerminalPane)
+	if !ok {
+		return fmt.Errorf("no active terminal pane found")
+	}
+	if len(m.pasteBuffer) == 0 {
+		return nil
+	}
+	// line endings are sent as carriage returns, as if they were typed
+	data := bytes.Replace(m.pasteBuffer, []byte("\n"), []byte("\r"), -1)
+	active.Terminal().Lock()
+	bracketed := active.Terminal().GetActiveBuffer().IsBracketedPasteMode()
+	active.Terminal().Unlock()
+	if bracketed {
+		data = append(append([]byte("\x1b[200~"), data...), []byte("\x1b[201~")...)
+	}
+	return active.HandleStdIn(data)
+}
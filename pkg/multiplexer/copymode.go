@@ -0,0 +1,34 @@
+package multiplexer
+
+import (
+	"fmt"
+
+	"github.com/liamg/sunder/pkg/pane"
+)
+
+// EnterCopyMode switches the active pane into copy mode, where the scrollback can be browsed, searched and yanked
+func (m *Multiplexer) EnterCopyMode() (*pane.CopyMode, error) {
+	active, ok := m.rootPane.FindActive().(*pane.TerminalPane)
+	if !ok {
+		return nil, fmt.Errorf("no active terminal pane found")
+	}
+	copyMode := active.EnterCopyMode(m.yank)
+	m.updateIndicators()
+	return copyMode, nil
+}
+
+// SearchActivePane enters copy mode and opens the search prompt
+func (m *Multiplexer) SearchActivePane(backward bool) error {
+	copyMode, err := m.EnterCopyMode()
+	if err != nil {
+		return err
+	}
+	copyMode.StartSearch(backward)
+	return nil
+}
+
+// yank stores text yanked in copy mode in the paste buffer and the clipboard of the parent terminal
+func (m *Multiplexer) yank(data []byte) {
+	m.pasteBuffer = data
+	m.setClipboard("c", data)
+}
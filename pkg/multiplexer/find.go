@@ -0,0 +1,87 @@
+package multiplexer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/liamg/sunder/pkg/overlay"
+	"github.com/liamg/sunder/pkg/pane"
+)
+
+// the maximum number of matches listed when searching all panes
+const maxFindResults = 1000
+
+type findResult struct {
+	pane    *pane.TerminalPane
+	pattern string
+	match   pane.Match
+}
+
+// findPane is the text of a pane as it was when the finder was opened, so each key typed searches it without
+// reading the scrollback of every pane again
+type findPane struct {
+	pane  *pane.TerminalPane
+	name  string
+	lines []string
+}
+
+// FindInAllPanes opens a chooser which searches the screen and scrollback of every pane as the user types. Choosing a
+// match focuses its pane and shows the match in copy mode.
+func (m *Multiplexer) FindInAllPanes() {
+	var panes []findPane
+	for _, terminalPane := range pane.TerminalPanes(m.rootPane) {
+		if !terminalPane.Exists() {
+			continue
+		}
+		panes = append(panes, findPane{
+			pane:  terminalPane,
+			name:  m.paneName(terminalPane),
+			lines: terminalPane.Lines(),
+		})
+	}
+	m.openOverlay(overlay.NewChooser("Find in all panes", func(query string) []overlay.Item {
+		return findItems(panes, query)
+	}, m.selectFindResult))
+}
+
+func findItems(panes []findPane, query string) []overlay.Item {
+
+	if query == "" {
+		return nil
+	}
+
+	expr, err := pane.CompileSearch(query, false)
+	if err != nil {
+		return nil
+	}
+
+	var items []overlay.Item
+	for _, p := range panes {
+		for i, text := range p.lines {
+			for _, match := range pane.MatchLine(expr, i, text) {
+				items = append(items, overlay.Item{
+					Label: fmt.Sprintf("%s, line %d: %s", p.name, match.Line+1, strings.TrimSpace(pane.VisibleText(text))),
+					Value: findResult{
+						pane:    p.pane,
+						pattern: query,
+						match:   match,
+					},
+				})
+				if len(items) >= maxFindResults {
+					return items
+				}
+			}
+		}
+	}
+	return items
+}
+
+func (m *Multiplexer) selectFindResult(item overlay.Item) {
+	result, ok := item.Value.(findResult)
+	if !ok || !result.pane.Exists() {
+		return
+	}
+	m.rootPane.SetActive(result.pane)
+	m.updateIndicators()
+	result.pane.EnterCopyMode(m.yank).ShowMatch(result.pattern, false, result.match)
+}
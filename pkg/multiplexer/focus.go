@@ -0,0 +1,64 @@
+package multiplexer
+
+import "github.com/liamg/sunder/pkg/pane"
+
+// reportFocusChange tells the programs in panes which asked to know about focus (mode 1004) when focus moves
+// between them. If the parent terminal doesn't have focus, neither pane does.
+func (m *Multiplexer) reportFocusChange(previous *pane.TerminalPane, active *pane.TerminalPane) {
+	m.focusLock.Lock()
+	unfocused := m.unfocused
+	m.focusLock.Unlock()
+	if unfocused {
+		return
+	}
+	if previous.Exists() {
+		m.queueFocusReport(previous, false)
+	}
+	m.queueFocusReport(active, true)
+}
+
+// handleOuterFocus passes on focus reports from the parent terminal to the active pane
+func (m *Multiplexer) handleOuterFocus(focused bool) {
+	m.focusLock.Lock()
+	m.unfocused = !focused
+	active := m.focusedPane
+	m.focusLock.Unlock()
+	if active != nil {
+		m.queueFocusReport(active, focused)
+	}
+}
+
+// queueFocusReport queues a focus report for deliverFocusReports. Reports are written to panes from their own
+// goroutine, as a program which isn't reading its input would otherwise block rendering or input. Only the newest
+// report for each pane is kept, so reports can't pile up, and a pane is always told its latest state.
+func (m *Multiplexer) queueFocusReport(p *pane.TerminalPane, focused bool) {
+	m.focusLock.Lock()
+	if _, queued := m.focusReports[p]; !queued {
+		m.focusQueue = append(m.focusQueue, p)
+	}
+	m.focusReports[p] = focused
+	m.focusLock.Unlock()
+	select {
+	case m.focusQueued <- struct{}{}:
+	default:
+	}
+}
+
+// deliverFocusReports writes queued focus reports to their panes, in the order they were queued, until the
+// multiplexer is closed
+func (m *Multiplexer) deliverFocusReports() {
+	for {
+		select {
+		case <-m.focusQueued:
+		case <-m.closeChan:
+			return
+		}
+		m.focusLock.Lock()
+		queue, reports := m.focusQueue, m.focusReports
+		m.focusQueue, m.focusReports = nil, make(map[*pane.TerminalPane]bool)
+		m.focusLock.Unlock()
+		for _, p := range queue {
+			p.ReportFocus(reports[p])
+		}
+	}
+}
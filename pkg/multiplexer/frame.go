@@ -0,0 +1,52 @@
+package multiplexer
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/liamg/sunder/pkg/ansi"
+)
+
+// frameWriter collects everything drawn for a frame, so it reaches the parent terminal in a single write rather
+// than as a stream of small escape sequences
+type frameWriter struct {
+	buffer bytes.Buffer
+	output io.Writer
+	// wrap each frame in a synchronized update, so the parent terminal never shows a partly drawn frame
+	synchronized bool
+}
+
+func (f *frameWriter) Write(data []byte) (int, error) {
+	return f.buffer.Write(data)
+}
+
+// Flush writes the frame to the output and starts a new one
+func (f *frameWriter) Flush() error {
+	if f.buffer.Len() == 0 {
+		return nil
+	}
+	frame := f.buffer.Bytes()
+	if f.synchronized {
+		frame = append(append([]byte(ansi.BeginSynchronizedUpdate), frame...), ansi.EndSynchronizedUpdate...)
+	}
+	_, err := f.output.Write(frame)
+	f.buffer.Reset()
+	return err
+}
+
+// draw runs fn with exclusive access to the parent terminal, then sends everything it drew in a single write
+func (m *Multiplexer) draw(fn func(w *ansi.Writer)) {
+	m.renderLock.Lock()
+	defer m.renderLock.Unlock()
+	fn(m.stdoutWriter)
+	m.flush()
+}
+
+// flush writes the current frame to the parent terminal, and must be called with the render lock held. If the
+// parent terminal can't be written to, the multiplexer is closed and Start returns the error.
+func (m *Multiplexer) flush() {
+	if err := m.frame.Flush(); err != nil && m.outputErr == nil {
+		m.outputErr = err
+		go m.Close()
+	}
+}
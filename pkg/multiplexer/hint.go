@@ -0,0 +1,37 @@
+package multiplexer
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/liamg/sunder/pkg/pane"
+)
+
+// EnterHintMode labels URLs, paths, hashes and other interesting text in the active pane so they can be copied or
+// opened by typing their label
+func (m *Multiplexer) EnterHintMode() (*pane.HintMode, error) {
+	active, ok := m.rootPane.FindActive().(*pane.TerminalPane)
+	if !ok {
+		return nil, fmt.Errorf("no active terminal pane found")
+	}
+	hintMode, err := active.EnterHintMode(m.config.HintPatterns, m.selectHint)
+	if err != nil {
+		return nil, err
+	}
+	m.updateIndicators()
+	return hintMode, nil
+}
+
+// selectHint copies the text of the chosen hint, or opens it with the configured command
+func (m *Multiplexer) selectHint(text string, open bool) {
+	if !open || m.config.HintOpenCommand == "" {
+		m.yank([]byte(text))
+		return
+	}
+	// the hint is passed as an argument rather than interpolated, so it can't be interpreted by the shell
+	cmd := exec.Command("/bin/sh", "-c", m.config.HintOpenCommand+` "$1"`, "sh", text)
+	if err := cmd.Start(); err != nil {
+		return
+	}
+	go func() { _ = cmd.Wait() }()
+}
@@ -0,0 +1,66 @@
+package multiplexer
+
+import (
+	"fmt"
+
+	"github.com/liamg/sunder/pkg/config"
+	"github.com/liamg/sunder/pkg/pane"
+)
+
+// runHooks runs the commands configured for a lifecycle event in the background. The event and any context, such
+// as the pane involved, are passed to the commands in environment variables.
+func (m *Multiplexer) runHooks(hook config.Hook, env ...string) {
+	env = append([]string{"SUNDER_EVENT=" + string(hook)}, env...)
+	for _, command := range m.config.Hooks[hook] {
+		_ = runCommand(command, env...)
+	}
+}
+
+// paneEnv describes a pane to hook commands
+func (m *Multiplexer) paneEnv(p *pane.TerminalPane) []string {
+	return []string{
+		"SUNDER_PANE=" + m.paneName(p),
+		"SUNDER_PANE_NAME=" + p.Name(),
+		"SUNDER_PANE_TITLE=" + p.Terminal().GetTitle(),
+	}
+}
+
+// sizeEnv describes the size of the session to hook commands
+func sizeEnv(rows, cols uint16) []string {
+	return []string{
+		fmt.Sprintf("SUNDER_ROWS=%d", rows),
+		fmt.Sprintf("SUNDER_COLS=%d", cols),
+	}
+}
+
+// checkFocus updates the status bar and runs the focus-changed hooks if the active pane is not the one which was
+// active last time we checked
+func (m *Multiplexer) checkFocus() {
+	active, ok := m.rootPane.FindActive().(*pane.TerminalPane)
+	if !ok {
+		return
+	}
+
+	m.focusLock.Lock()
+	previous := m.focusedPane
+	m.focusedPane = active
+	m.focusLock.Unlock()
+
+	if previous == active {
+		return
+	}
+	m.updateIndicators()
+
+	// the first pane gaining focus when the session starts isn't a change
+	if previous == nil {
+		return
+	}
+
+	m.reportFocusChange(previous, active)
+
+	env := m.paneEnv(active)
+	if previous.Exists() {
+		env = append(env, "SUNDER_PREVIOUS_PANE="+m.paneName(previous))
+	}
+	m.runHooks(config.HookFocusChanged, env...)
+}
@@ -1,14 +1,51 @@
 package multiplexer
 
-import "github.com/liamg/sunder/pkg/pane"
+import (
+	"unicode"
 
-func (m *Multiplexer) handleShortcut(input byte) {
-	switch input {
+	"github.com/liamg/sunder/pkg/input"
+	"github.com/liamg/sunder/pkg/pane"
+)
+
+func (m *Multiplexer) handleShortcut(key input.Key) {
+	// shortcuts are typed characters, so ctrl and alt combinations don't match any of them
+	if key.Code != input.KeyRune || key.Modifiers&(input.ModCtrl|input.ModAlt) != 0 {
+		return
+	}
+	r := key.Rune
+	if key.Modifiers&input.ModShift != 0 {
+		r = unicode.ToUpper(r)
+	}
+	switch r {
 	case 'v':
 		// TODO how to handle errors here? message box? output to stdout in active pane?
 		_ = m.SplitActivePane(pane.Vertical)
+		m.updateIndicators()
 	case 'h':
 		// TODO how to handle errors here? message box? output to stdout in active pane?
 		_ = m.SplitActivePane(pane.Horizontal)
+		m.updateIndicators()
+	case 's':
+		m.ToggleSynchronize()
+	case 'm':
+		_ = m.ToggleMarkActivePane()
+	case 'p':
+		_ = m.CapturePaneToBuffer()
+	case 'P':
+		_, _ = m.CapturePaneToFile()
+	case ']':
+		_ = m.Paste()
+	case 'o':
+		_ = m.TogglePipeActivePane()
+	case 'r':
+		_ = m.ToggleRecording()
+	case '[':
+		_, _ = m.EnterCopyMode()
+	case '/':
+		_ = m.SearchActivePane(true)
+	case 'f':
+		m.FindInAllPanes()
+	case 'u':
+		_, _ = m.EnterHintMode()
 	}
 }
@@ -0,0 +1,85 @@
+package multiplexer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/liamg/sunder/pkg/ansi"
+	"github.com/liamg/sunder/pkg/pane"
+)
+
+// duration of the visual flash when a pane raises an alert
+const alertFlashDuration = 100 * time.Millisecond
+
+// monitoredAlerts returns the alerts enabled in the config
+func (m *Multiplexer) monitoredAlerts() pane.Alert {
+	var alerts pane.Alert
+	if m.config.MonitorActivity {
+		alerts |= pane.AlertActivity
+	}
+	if m.config.MonitorSilence > 0 {
+		alerts |= pane.AlertSilence
+	}
+	if m.config.MonitorBell {
+		alerts |= pane.AlertBell
+	}
+	return alerts
+}
+
+// handleAlert flags a pane in the status bar and lets the user know, as configured
+func (m *Multiplexer) handleAlert(p *pane.TerminalPane, alert pane.Alert) {
+
+	if alert == pane.AlertBell {
+		m.draw(func(w *ansi.Writer) {
+			w.Bell()
+		})
+	}
+
+	// the active pane isn't flagged, the bell is enough
+	if p.Alerts()&alert == 0 {
+		return
+	}
+
+	m.updateIndicators()
+
+	if m.config.AlertNotify {
+		message := fmt.Sprintf("sunder: %s in %s", strings.ToLower(alert.String()), m.paneName(p))
+		m.draw(func(w *ansi.Writer) {
+			w.Notify(message)
+		})
+	}
+
+	if m.config.AlertFlash {
+		m.draw(func(w *ansi.Writer) {
+			w.SetReverseVideo(true)
+		})
+		time.AfterFunc(alertFlashDuration, func() {
+			m.draw(func(w *ansi.Writer) {
+				w.SetReverseVideo(false)
+			})
+		})
+	}
+}
+
+// monitorSilence periodically checks whether panes have gone quiet, until the multiplexer is closed
+func (m *Multiplexer) monitorSilence() {
+	if m.config.MonitorSilence <= 0 {
+		return
+	}
+	duration := time.Duration(m.config.MonitorSilence) * time.Second
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, p := range pane.TerminalPanes(m.rootPane) {
+				if p.Exists() {
+					p.CheckSilence(duration)
+				}
+			}
+		case <-m.closeChan:
+			return
+		}
+	}
+}
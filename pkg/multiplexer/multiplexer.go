@@ -4,28 +4,46 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"os/signal"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/liamg/sunder/pkg/ansi"
+	"github.com/liamg/sunder/pkg/asciicast"
+	"github.com/liamg/sunder/pkg/capability"
+	"github.com/liamg/sunder/pkg/config"
+	"github.com/liamg/sunder/pkg/overlay"
 
 	"github.com/liamg/sunder/pkg/pane"
 
 	"github.com/creack/pty"
-	"github.com/liamg/termutil/pkg/termutil"
+	"github.com/liamg/sunder/pkg/termutil"
 	"golang.org/x/crypto/ssh/terminal"
 )
 
 type Multiplexer struct {
+	config *config.Config
 	// root pane
 	rootPane   pane.Pane
 	activePane pane.Pane
-	// write to this to get stdout on the parent terminal
-	output       chan byte
+	statusPane *pane.StatusPane
+	// write to this to draw on the parent terminal, then flush to send the frame
 	stdoutWriter *ansi.Writer
+	frame        *frameWriter
+	// the first error writing to the parent terminal
+	outputErr error
+	// what the parent terminal can display, which is detected when starting unless it has been set
+	capabilities      capability.Capabilities
+	fixedCapabilities bool
+	// the default foreground and background colours of the parent terminal, as it reported them, for answering
+	// queries from panes
+	outerColours    [2]string
+	outerColourLock sync.Mutex
 	// panes write to this channel to request to be rendered by the multiplexer
-	updateChan       <-chan pane.Pane
+	updateChan       chan pane.Pane
+	scheduler        *renderScheduler
 	closeChan        chan struct{}
 	closeOnce        sync.Once
 	rows             uint16
@@ -34,28 +52,145 @@ type Multiplexer struct {
 	paneLock         sync.Mutex
 	waitGroup        sync.WaitGroup
 	inEscapeSequence bool
+	// when enabled, input is sent to all (or all marked) terminal panes instead of just the active one
+	synchronize bool
+	// text captured from panes which can be pasted into the active pane
+	pasteBuffer []byte
+	// session recording, and optional raw recordings of each pane
+	recorder        *asciicast.Recorder
+	recordingPrefix string
+	paneRecorders   map[*pane.TerminalPane]*paneRecording
+	paneRecordings  int
+	recordLock      sync.Mutex
+	// overlay is drawn over all panes and receives all input while it is open
+	overlay     overlay.Overlay
+	overlayLock sync.Mutex
+	// the parent terminal, and its size if it shouldn't be read from stdin
+	stdin     io.Reader
+	stdout    io.Writer
+	fixedRows uint16
+	fixedCols uint16
+	// sizes receives the size of the parent terminal whenever it changes, if it isn't read from stdin
+	sizes <-chan Size
+	// shell creates the command run in each new pane, or the user's shell is used if it is nil
+	shell           func() *exec.Cmd
+	paneFactory     PaneFactory
+	rootPaneFactory RootPaneFactory
+	statusBarAnchor pane.Anchor
+	// the active pane when focus was last checked, so focus-changed hooks can be run
+	focusedPane *pane.TerminalPane
+	// the parent terminal has reported that it lost focus
+	unfocused bool
+	focusLock sync.Mutex
+	// focus reports waiting to be written to panes, the order the panes were queued in, and a signal that reports
+	// are waiting
+	focusReports map[*pane.TerminalPane]bool
+	focusQueue   []*pane.TerminalPane
+	focusQueued  chan struct{}
+	// the start of a possible report from the parent terminal, held back until the rest of it is read, and how many
+	// have been held back, so a timer can tell if the one it was started for is still waiting
+	partialInput []byte
+	partialTimer *time.Timer
+	partialCount int
+	// input is part way through a bracketed paste
+	pastingInput bool
+	inputLock    sync.Mutex
 }
 
-func New() *Multiplexer {
-	update := make(chan pane.Pane, 0xff)
-	out := make(chan byte, 0xffff)
-	stdoutWriter := NewChanWriter(out)
+// Size is the size of the area the multiplexer draws into
+type Size struct {
+	Rows uint16
+	Cols uint16
+}
 
-	terminalPane := pane.NewTerminalPane(update, termutil.New(termutil.WithLogFile("/tmp/sunder.log")))
-	container := pane.NewContainerPane(update, pane.Horizontal, terminalPane)
-	status := pane.NewStatusPane(update, container, pane.Bottom)
+// New creates a multiplexer which runs in the terminal attached to stdin and stdout
+func New(options ...Option) *Multiplexer {
+	update := make(chan pane.Pane, 0xff)
 
 	mp := &Multiplexer{
-		rootPane:     status,
-		activePane:   terminalPane,
-		output:       out,
-		updateChan:   update,
-		closeChan:    make(chan struct{}),
-		stdoutWriter: ansi.NewWriter(stdoutWriter),
+		config:          config.Default(),
+		updateChan:      update,
+		scheduler:       newRenderScheduler(),
+		capabilities:    capability.Full(),
+		closeChan:       make(chan struct{}),
+		focusReports:    make(map[*pane.TerminalPane]bool),
+		focusQueued:     make(chan struct{}, 1),
+		stdin:           os.Stdin,
+		stdout:          os.Stdout,
+		paneFactory:     defaultPaneFactory,
+		statusBarAnchor: pane.Bottom,
+	}
+
+	for _, option := range options {
+		option(mp)
 	}
+
+	mp.frame = &frameWriter{output: &recordingWriter{m: mp, writer: mp.stdout}}
+	mp.stdoutWriter = ansi.NewWriter(mp.frame)
+
+	factory := mp.rootPaneFactory
+	if factory == nil {
+		factory = defaultRootPane
+	}
+	root, active := factory(update, func(options ...termutil.Option) *pane.TerminalPane {
+		return mp.newTerminalPane(update, options...)
+	})
+	mp.statusPane = pane.NewStatusPane(update, root, mp.statusBarAnchor)
+	mp.rootPane = mp.statusPane
+	mp.activePane = active
+
 	return mp
 }
 
+// defaultRootPane starts the multiplexer with a single terminal pane, in a container so it can be split
+func defaultRootPane(updateChan chan<- pane.Pane, newPane NewPaneFunc) (pane.Pane, pane.Pane) {
+	terminalPane := newPane(termutil.WithLogFile("/tmp/sunder.log"))
+	return pane.NewContainerPane(updateChan, pane.Horizontal, terminalPane), terminalPane
+}
+
+// NewWithIO creates a multiplexer which reads keyboard input from input and draws to output, e.g. to embed it in
+// another program. It is resized whenever a size is received from sizes, and Start waits for the first size.
+func NewWithIO(input io.Reader, output io.Writer, sizes <-chan Size, options ...Option) *Multiplexer {
+	return New(append([]Option{WithInput(input), WithOutput(output), WithSizes(sizes)}, options...)...)
+}
+
+func (m *Multiplexer) newTerminalPane(updateChan chan<- pane.Pane, options ...termutil.Option) *pane.TerminalPane {
+	var terminalPane *pane.TerminalPane
+	if m.shell != nil {
+		options = append(options, termutil.WithCommand(m.shell()))
+	}
+	options = append(options,
+		termutil.WithClipboardHandler(m.setClipboard),
+		termutil.WithDynamicColourHandler(m.outerColour),
+		termutil.WithVersion("sunder"),
+		termutil.WithBellHandler(func() { terminalPane.Bell() }),
+		termutil.WithNotificationHandler(func(title string, body string) {
+			m.notify(terminalPane, title, body)
+		}),
+		termutil.WithTitleHandler(func(string) {
+			m.runHooks(config.HookWindowRenamed, m.paneEnv(terminalPane)...)
+		}),
+	)
+	terminalPane = m.paneFactory(updateChan, options...)
+	terminalPane.Monitor(m.monitoredAlerts(), func(alert pane.Alert) {
+		m.handleAlert(terminalPane, alert)
+	})
+	terminalPane.SetExitHandler(func(exitCode int) {
+		m.runHooks(config.HookPaneExited, append(m.paneEnv(terminalPane), fmt.Sprintf("SUNDER_EXIT_STATUS=%d", exitCode))...)
+	})
+	return terminalPane
+}
+
+// setClipboard sets the clipboard of the parent terminal, if enabled
+func (m *Multiplexer) setClipboard(selection string, data []byte) {
+	if !m.config.Clipboard {
+		return
+	}
+	m.draw(func(w *ansi.Writer) {
+		w.SetClipboard(selection, data)
+	})
+}
+
 func (m *Multiplexer) SplitActivePane(mode pane.SplitMode) error {
 	active := m.rootPane.FindActive()
 	if active == nil {
@@ -65,55 +200,75 @@ func (m *Multiplexer) SplitActivePane(mode pane.SplitMode) error {
 	if !ok {
 		return fmt.Errorf("root pane does not support splitting")
 	}
-	if !splitter.Split(active, mode) {
+	newPane := m.newTerminalPane(m.updateChan)
+	if !splitter.Split(active, newPane, mode) {
 		return fmt.Errorf("failed to split active pane")
 	}
+	m.runHooks(config.HookPaneCreated, m.paneEnv(newPane)...)
 	return nil
 }
 
 func (m *Multiplexer) Start() error {
 
+	// nothing is drawn until everything has been set up, and the lock is released if setting up fails
 	m.renderLock.Lock()
+	started := false
+	defer func() {
+		if !started {
+			m.renderLock.Unlock()
+		}
+	}()
 
 	// TODO for debugging, remove later
 	_ = os.Setenv("SUNDER", "1")
 
 	// RIS
 	m.stdoutWriter.Reset()
-
-	// Handle pty size.
-	ch := make(chan os.Signal, 1)
-	signal.Notify(ch, syscall.SIGWINCH)
-	m.waitGroup.Add(1)
-	go func() {
-		defer m.waitGroup.Done()
-		for {
-			select {
-			case <-ch:
-
-				size, err := pty.GetsizeFull(os.Stdin)
-				if err != nil {
-					continue
-				}
-
-				_ = m.resize(size.Rows, size.Cols)
-			case <-m.closeChan:
-				return
-			}
+	m.detectCapabilities()
+	m.queryOuterTerminal()
+	m.stdoutWriter.SetFocusReporting(true)
+	// keypad keys are sent to each pane in the mode it has chosen, which needs them to be distinguishable
+	m.stdoutWriter.SetApplicationKeypad(true)
+	m.stdoutWriter.SetKeyboardEnhancements(true)
+
+	// follow the size of the parent terminal unless we've been given a size
+	rows, cols := m.fixedRows, m.fixedCols
+	sizes := m.sizes
+	if sizes == nil && (rows == 0 || cols == 0) {
+		tty, ok := m.stdin.(*os.File)
+		if !ok {
+			return fmt.Errorf("a size must be provided when input is not a terminal")
 		}
-	}()
-	ch <- syscall.SIGWINCH // Initial resize.
-
-	// Set stdin in raw mode.
-	oldState, err := terminal.MakeRaw(int(os.Stdin.Fd()))
-	if err != nil {
+		size, err := pty.GetsizeFull(tty)
+		if err != nil {
+			return err
+		}
+		rows, cols = size.Rows, size.Cols
+		sizes = m.terminalSizes(tty)
+	}
+	if rows == 0 || cols == 0 {
+		size, ok := <-sizes
+		if !ok {
+			return fmt.Errorf("no size was received")
+		}
+		rows, cols = size.Rows, size.Cols
+	}
+	if err := m.Resize(rows, cols); err != nil {
 		return err
 	}
-	defer func() { _ = terminal.Restore(int(os.Stdin.Fd()), oldState) }() // Best effort restore.
 
-	size, err := pty.GetsizeFull(os.Stdin)
-	if err != nil {
-		return err
+	// Set stdin in raw mode, if it is a terminal
+	if tty, ok := m.stdin.(*os.File); ok && terminal.IsTerminal(int(tty.Fd())) {
+		oldState, err := terminal.MakeRaw(int(tty.Fd()))
+		if err != nil {
+			return err
+		}
+		defer func() { _ = terminal.Restore(int(tty.Fd()), oldState) }() // Best effort restore.
+	}
+
+	// only follow the size once nothing else can fail
+	if sizes != nil {
+		m.followSizes(sizes)
 	}
 
 	// kick off root pane
@@ -121,54 +276,154 @@ func (m *Multiplexer) Start() error {
 	m.waitGroup.Add(1)
 	go func() {
 		defer m.waitGroup.Done()
-		_ = m.rootPane.Start(size.Rows, size.Cols)
+		_ = m.rootPane.Start(rows, cols)
 	}()
 
 	// tidy up root pane on exit
 	defer m.rootPane.Close()
 
-	// Copy stdin to the multiplexer and the multiplexer output to stdout.
+	m.runHooks(config.HookSessionAttached, sizeEnv(rows, cols)...)
+	for _, p := range pane.TerminalPanes(m.rootPane) {
+		m.runHooks(config.HookPaneCreated, m.paneEnv(p)...)
+	}
+
 	m.waitGroup.Add(1)
 	go func() {
 		defer m.waitGroup.Done()
-		_, _ = io.Copy(m, os.Stdin)
+		m.monitorSilence()
 	}()
 
 	m.waitGroup.Add(1)
 	go func() {
 		defer m.waitGroup.Done()
-		for {
-			select {
-			case p := <-m.updateChan:
-				m.render(p)
-			case <-m.closeChan:
-				return
-			}
-		}
+		m.watchNames()
 	}()
 
+	m.waitGroup.Add(1)
+	go func() {
+		defer m.waitGroup.Done()
+		m.deliverFocusReports()
+	}()
+
+	// Copy stdin to the multiplexer and the multiplexer output to stdout.
+	m.waitGroup.Add(1)
+	go func() {
+		defer m.waitGroup.Done()
+		m.readInput()
+	}()
+
+	m.waitGroup.Add(2)
+	go func() {
+		defer m.waitGroup.Done()
+		m.collectUpdates()
+	}()
+	go func() {
+		defer m.waitGroup.Done()
+		m.renderFrames()
+	}()
+
+	m.flush()
+	started = true
 	m.renderLock.Unlock()
-	_, err = io.Copy(os.Stdout, m)
 
-	return err
+	<-m.closeChan
+
+	m.renderLock.Lock()
+	defer m.renderLock.Unlock()
+	// leave the parent terminal's input modes and cursor as we found them, if it is still there
+	if m.outputErr == nil {
+		m.stdoutWriter.SetCursorStyle(0)
+		m.stdoutWriter.SetFocusReporting(false)
+		m.stdoutWriter.SetApplicationKeypad(false)
+		m.stdoutWriter.SetKeyboardEnhancements(false)
+		_ = m.frame.Flush()
+	}
+	return m.outputErr
 
 }
 
+// readInput passes input on to the multiplexer until the input is closed. Unlike io.Copy, it keeps going if the
+// input can't be delivered, e.g. because the shell in the active pane hasn't started yet.
+func (m *Multiplexer) readInput() {
+	buf := make([]byte, 4096)
+	for {
+		n, err := m.stdin.Read(buf)
+		if n > 0 {
+			m.readReports(buf[:n])
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
 func (m *Multiplexer) Close() {
 
 	m.paneLock.Lock()
-	defer m.paneLock.Unlock()
-
 	m.rootPane.Close()
+	m.paneLock.Unlock()
+
+	m.stopRecording()
 
 	m.closeOnce.Do(func() {
+		m.runHooks(config.HookSessionDetached, sizeEnv(m.size())...)
 		close(m.closeChan)
 	})
 
 	m.waitGroup.Wait()
+	pane.WaitForPipes()
 }
 
-func (m *Multiplexer) resize(rows uint16, cols uint16) error {
+// terminalSizes sends the size of the terminal whenever it is resized, until the multiplexer is closed
+func (m *Multiplexer) terminalSizes(tty *os.File) <-chan Size {
+	sizes := make(chan Size)
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGWINCH)
+	m.waitGroup.Add(1)
+	go func() {
+		defer m.waitGroup.Done()
+		defer signal.Stop(ch)
+		for {
+			select {
+			case <-ch:
+				size, err := pty.GetsizeFull(tty)
+				if err != nil {
+					continue
+				}
+				select {
+				case sizes <- Size{Rows: size.Rows, Cols: size.Cols}:
+				case <-m.closeChan:
+					return
+				}
+			case <-m.closeChan:
+				return
+			}
+		}
+	}()
+	return sizes
+}
+
+// followSizes resizes the multiplexer whenever a size is received, until the multiplexer is closed
+func (m *Multiplexer) followSizes(sizes <-chan Size) {
+	m.waitGroup.Add(1)
+	go func() {
+		defer m.waitGroup.Done()
+		for {
+			select {
+			case size, ok := <-sizes:
+				if !ok {
+					return
+				}
+				_ = m.Resize(size.Rows, size.Cols)
+			case <-m.closeChan:
+				return
+			}
+		}
+	}()
+}
+
+// Resize changes the size of the area the multiplexer draws into
+func (m *Multiplexer) Resize(rows uint16, cols uint16) error {
 	m.paneLock.Lock()
 	defer m.paneLock.Unlock()
 	// resize root pane
@@ -179,30 +434,67 @@ func (m *Multiplexer) resize(rows uint16, cols uint16) error {
 
 	m.cols = cols
 	m.rows = rows
+	m.runHooks(config.HookResize, sizeEnv(rows, cols)...)
+
+	m.recordLock.Lock()
+	if m.recorder != nil {
+		_ = m.recorder.Resize(cols, rows)
+	}
+	m.recordLock.Unlock()
 	return nil
 }
 
-func (m *Multiplexer) render(target pane.Pane) {
+// size returns the size of the area the multiplexer draws into
+func (m *Multiplexer) size() (rows uint16, cols uint16) {
+	m.paneLock.Lock()
+	defer m.paneLock.Unlock()
+	return m.rows, m.cols
+}
+
+// renderAll redraws every pane in the next frame
+func (m *Multiplexer) renderAll() {
+	m.scheduler.markDirty(m.statusPane)
+	for _, child := range m.statusPane.Children() {
+		m.scheduler.markDirty(child)
+	}
+}
+
+// render draws a frame containing each of the targets
+func (m *Multiplexer) render(targets ...pane.Pane) {
+	m.drawFrame(targets...)
+	// outside the render lock, as focus changes are reported to panes and run hooks
+	m.checkFocus()
+	m.updatePaneRecordings()
+}
+
+// drawFrame draws each of the targets, followed by the active pane and any overlay, and sends the frame to the
+// parent terminal
+func (m *Multiplexer) drawFrame(targets ...pane.Pane) {
 
 	m.renderLock.Lock()
 	defer m.renderLock.Unlock()
+	defer m.flush()
 
 	if !m.rootPane.Exists() {
-		m.Close()
+		// close in the background, as closing waits for this render loop to finish
+		go m.Close()
 		return
 	}
 
-	//	start := time.Now()
-	m.rootPane.Render(target, 0, 0, m.rows, m.cols, m.stdoutWriter)
-	//	fullRenderDuration := time.Since(start)
-
-	//	logger.Log("Full render: %s", fullRenderDuration)
+	rows, cols := m.size()
+	for _, target := range targets {
+		m.rootPane.Render(target, 0, 0, rows, cols, m.stdoutWriter)
+	}
 
 	// render active again to fix cursor position etc.
 	active := m.rootPane.FindActive()
 	//if active != target {
-	m.rootPane.Render(active, 0, 0, m.rows, m.cols, m.stdoutWriter)
+	m.rootPane.Render(active, 0, 0, rows, cols, m.stdoutWriter)
 	//	logger.Log("Active: %s", time.Since(start)-fullRenderDuration)
 	//}
 
+	if o := m.currentOverlay(); o != nil {
+		o.Render(0, 0, rows, cols, m.stdoutWriter)
+	}
+
 }
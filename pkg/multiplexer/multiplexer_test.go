@@ -0,0 +1,628 @@
+package multiplexer_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/liamg/sunder/pkg/capability"
+	"github.com/liamg/sunder/pkg/config"
+	"github.com/liamg/sunder/pkg/multiplexer"
+	"github.com/liamg/sunder/pkg/pane"
+	"github.com/liamg/sunder/pkg/screen"
+	"github.com/liamg/sunder/pkg/sundertest"
+	"github.com/liamg/sunder/pkg/termutil"
+)
+
+func TestStartDrawsShellAndStatusBar(t *testing.T) {
+	h := sundertest.New(t, 10, 60)
+	h.WaitFor("$")
+	h.WaitUntil("status bar on the bottom row", func(s *screen.Screen) bool {
+		return strings.HasPrefix(s.Line(9), " Sunder ")
+	})
+}
+
+func TestCommandOutputIsShownInPane(t *testing.T) {
+	h := sundertest.New(t, 10, 60)
+	h.WaitFor("$")
+	// quote the text so it only appears on screen once the command has run
+	h.Run("echo 'hello'' world'")
+	h.WaitFor("hello world")
+}
+
+func TestSplitShowsDividerAndSendsInputToNewPane(t *testing.T) {
+	h := sundertest.New(t, 10, 60)
+	h.WaitFor("$")
+	h.Type("\x01v")
+	h.WaitFor("┃")
+	h.Run("echo 'second'' pane'")
+	h.WaitUntil("output in the right hand pane", func(s *screen.Screen) bool {
+		for y := uint16(0); y < 9; y++ {
+			line := []rune(s.Line(y))
+			if len(line) > 30 && string(line[30:]) == "second pane" {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func TestExitingLastPaneStopsMultiplexer(t *testing.T) {
+	h := sundertest.New(t, 10, 60)
+	h.WaitFor("$")
+	h.Run("exit")
+	if err := h.Wait(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestScriptedCommand(t *testing.T) {
+	h := sundertest.New(t, 10, 60, multiplexer.WithShell(func() *exec.Cmd {
+		return sundertest.Command("/bin/sh", "-c", "printf 'line one\\nline two\\n'; sleep 5")
+	}))
+	h.WaitFor("line one")
+	h.WaitFor("line two")
+}
+
+func TestEmbeddedWithSizesAndHiddenStatusBar(t *testing.T) {
+	output := screen.New(10, 60)
+	defer output.Close()
+	input, typing := io.Pipe()
+	sizes := make(chan multiplexer.Size, 1)
+	sizes <- multiplexer.Size{Rows: 10, Cols: 60}
+
+	m := multiplexer.NewWithIO(input, output, sizes,
+		multiplexer.WithShell(sundertest.Shell),
+		multiplexer.WithStatusBar(pane.Hidden),
+	)
+	done := make(chan error, 1)
+	go func() { done <- m.Start() }()
+	defer func() {
+		// stop reading input first, as closing waits for it
+		_ = typing.Close()
+		m.Close()
+		<-done
+	}()
+
+	_, _ = typing.Write([]byte("echo 'bottom'' row'\r"))
+
+	// without a status bar, the pane can scroll all the way to the bottom row
+	deadline := time.Now().Add(sundertest.Timeout)
+	for output.Line(9) == "" || output.Contains(" Sunder ") {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the pane to fill the screen, screen was:\n%s", output)
+		}
+		_, _ = typing.Write([]byte("\r"))
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+func TestFailedStartCanBeClosed(t *testing.T) {
+	input, typing := io.Pipe()
+	defer func() { _ = typing.Close() }()
+	// no size is ever received
+	sizes := make(chan multiplexer.Size)
+	close(sizes)
+	output := screen.New(10, 60)
+	defer output.Close()
+	m := multiplexer.NewWithIO(input, output, sizes, multiplexer.WithShell(sundertest.Shell))
+
+	done := make(chan error, 1)
+	go func() {
+		// a second attempt fails the same way, rather than waiting for the first to finish drawing
+		for i := 0; i < 2; i++ {
+			if err := m.Start(); err == nil {
+				done <- fmt.Errorf("expected start %d to fail", i+1)
+				return
+			}
+		}
+		m.Close()
+		done <- nil
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(sundertest.Timeout):
+		t.Fatal("timed out starting and closing the multiplexer")
+	}
+}
+
+func TestRootPaneCanBeSupplied(t *testing.T) {
+	h := sundertest.New(t, 10, 60, multiplexer.WithRootPane(func(updateChan chan<- pane.Pane, newPane multiplexer.NewPaneFunc) (pane.Pane, pane.Pane) {
+		left, right := newPane(), newPane()
+		return pane.NewContainerPane(updateChan, pane.Vertical, left, right), right
+	}))
+	h.WaitFor("┃$")
+	h.Run("echo 'right'' pane'")
+	h.WaitUntil("the command to run in the right pane", func(s *screen.Screen) bool {
+		for y := uint16(0); y < 9; y++ {
+			if strings.Contains(s.Line(y), "┃right pane") {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+func TestFindNumbersPanesLikeTheStatusBar(t *testing.T) {
+	var lock sync.Mutex
+	var panes int
+	h := sundertest.New(t, 10, 60, multiplexer.WithShell(func() *exec.Cmd {
+		lock.Lock()
+		defer lock.Unlock()
+		panes++
+		if panes == 1 {
+			// the first pane exits once the second has been split off, leaving the second numbered 1
+			return sundertest.Command("/bin/sh", "-c", "sleep 0.5")
+		}
+		return sundertest.Shell()
+	}))
+	h.Type("\x01v")
+	h.WaitFor("┃$")
+	h.WaitForGone("┃")
+	h.Run("echo 'find''me'")
+	h.WaitFor("findme")
+	h.WaitFor("Sunder 1:sh")
+	h.Type("\x01ffindme")
+	h.WaitFor("1:sh, line 2: findme")
+}
+
+func TestBusyPaneDoesNotStarveInputInAnotherPane(t *testing.T) {
+	h := sundertest.New(t, 10, 60)
+	h.WaitFor("$")
+	h.Run("yes")
+	h.WaitFor("y")
+	h.Type("\x01v")
+	h.WaitFor("┃$")
+	h.Type("typed while busy")
+	h.WaitFor("typed while busy")
+}
+
+func TestSynchronizedUpdateIsShownWhenFinished(t *testing.T) {
+	// the program asks whether a synchronized update is in progress once it has started one, and saves the reply, so
+	// the test knows the update has been processed before looking for anything drawn during it
+	reply := filepath.Join(t.TempDir(), "reply")
+	h := sundertest.New(t, 10, 60, multiplexer.WithShell(func() *exec.Cmd {
+		return sundertest.Command("/bin/sh", "-c", fmt.Sprintf(`stty raw -echo; printf 'visible\r\n'; sleep 0.2; printf '\033[?2026hhidden\r\n\033[?2026$p'; head -c 11 > '%s'; sleep 0.5; printf 'shown\033[?2026l'; sleep 5`, reply))
+	}))
+	h.WaitFor("visible")
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		if data, _ := os.ReadFile(reply); len(data) == 11 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the synchronized update to start")
+		}
+	}
+	if h.Screen().Contains("hidden") {
+		t.Fatalf("part of a synchronized update was drawn, screen was:\n%s", h.Screen())
+	}
+	h.WaitFor("shown")
+	h.WaitFor("hidden")
+}
+
+// syncRecorder draws to a screen, and records whether any frames were sent as synchronized updates
+type syncRecorder struct {
+	*screen.Screen
+	lock         sync.Mutex
+	synchronized bool
+}
+
+func (r *syncRecorder) Write(data []byte) (int, error) {
+	r.lock.Lock()
+	r.synchronized = r.synchronized || bytes.HasPrefix(data, []byte("\x1b[?2026h"))
+	r.lock.Unlock()
+	return r.Screen.Write(data)
+}
+
+func TestFramesAreSynchronizedWhenSupported(t *testing.T) {
+	output := &syncRecorder{Screen: screen.New(10, 60)}
+	defer output.Close()
+	input, typing := io.Pipe()
+
+	m := multiplexer.NewWithIO(input, output, nil,
+		multiplexer.WithShell(sundertest.Shell),
+		multiplexer.WithSize(10, 60),
+	)
+	done := make(chan error, 1)
+	go func() { done <- m.Start() }()
+	defer func() {
+		_ = typing.Close()
+		m.Close()
+		<-done
+	}()
+
+	deadline := time.Now().Add(sundertest.Timeout)
+	for !output.Contains("$") {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected a prompt, screen was:\n%s", output)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// reply to the query for mode 2026, then make sure the reply doesn't reach the shell
+	_, _ = typing.Write([]byte("\x1b[?2026;2$y"))
+	_, _ = typing.Write([]byte("echo 'after'' reply'\r"))
+
+	for !output.Contains("after reply") {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected command output, screen was:\n%s", output)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if output.Contains("2026") {
+		t.Fatalf("the reply was passed to the shell, screen was:\n%s", output)
+	}
+	output.lock.Lock()
+	defer output.lock.Unlock()
+	if !output.synchronized {
+		t.Fatal("expected frames to be sent as synchronized updates")
+	}
+}
+
+func TestASCIIDividersWithoutUTF8(t *testing.T) {
+	h := sundertest.New(t, 10, 60, multiplexer.WithCapabilities(capability.Capabilities{
+		Colours:    capability.Colours16,
+		Attributes: capability.AllAttributes,
+	}))
+	h.WaitFor("$")
+	h.Type("\x01v")
+	h.WaitFor("|$")
+	if h.Screen().Contains("┃") {
+		t.Fatalf("expected an ASCII divider, screen was:\n%s", h.Screen())
+	}
+}
+
+func TestWideAndCombiningCharacters(t *testing.T) {
+	h := sundertest.New(t, 10, 60, multiplexer.WithShell(func() *exec.Cmd {
+		return sundertest.Command("/bin/sh", "-c", `printf '日本語 e\314\201 😀 end\n'; sleep 5`)
+	}))
+	h.WaitFor("end")
+	if line := h.Screen().Line(0); line != "日本語 é 😀 end" {
+		t.Fatalf("expected wide and combining characters to line up, got %q", line)
+	}
+}
+
+func TestWideCharactersDoNotMoveDivider(t *testing.T) {
+	h := sundertest.New(t, 10, 60)
+	h.WaitFor("$")
+	// fill most of a line with wide characters, then split so they straddle the edge of the left pane
+	h.Run(`printf '\346\227\245%.0s' 1 2 3 4 5 6 7 8 9 10 11 12 13 14 15 16 17 18 19 20 21 22 23 24 25`)
+	h.WaitFor("日日日日日日日日日日日日日日日日日日日日日日日日日")
+	h.Type("\x01v")
+	h.WaitFor("┃$")
+	for y := uint16(0); y < 9; y++ {
+		column := 0
+		for _, r := range h.Screen().Line(y) {
+			if r == '┃' {
+				break
+			}
+			column += termutil.RuneWidth(r)
+		}
+		if column != 29 {
+			t.Fatalf("expected the divider in column 29 on row %d, screen was:\n%s", y, h.Screen())
+		}
+	}
+}
+
+func TestCursorStyleFollowsActivePane(t *testing.T) {
+	h := sundertest.New(t, 10, 60)
+	h.WaitFor("$")
+	// a steady bar, like vim uses in insert mode
+	h.Run(`printf '\033[6 q'`)
+	h.WaitUntil("the cursor to be a bar", func(s *screen.Screen) bool {
+		return s.CursorStyle() == 6
+	})
+	h.Type("\x01v")
+	h.WaitUntil("the new pane to have the default cursor", func(s *screen.Screen) bool {
+		return s.CursorStyle() == 0
+	})
+}
+
+func TestCursorStyleIsResetOnExit(t *testing.T) {
+	h := sundertest.New(t, 10, 60, multiplexer.WithShell(func() *exec.Cmd {
+		return sundertest.Command("/bin/sh", "-c", `printf '\033[6 qready\n'; sleep 0.5`)
+	}))
+	h.WaitUntil("the cursor to be a bar", func(s *screen.Screen) bool {
+		return s.CursorStyle() == 6
+	})
+	if err := h.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	h.WaitUntil("the cursor to be reset", func(s *screen.Screen) bool {
+		return s.CursorStyle() == 0
+	})
+}
+
+func TestFocusEventsArePassedToPanesWhichAskForThem(t *testing.T) {
+	h := sundertest.New(t, 10, 60, multiplexer.WithShell(func() *exec.Cmd {
+		return sundertest.Command("/bin/sh", "-c", `printf '\033[?1004hready\n'; stty -icanon -echo min 1; head -c 3 | od -An -c; sleep 5`)
+	}))
+	h.WaitFor("ready")
+	// the parent terminal losing focus
+	h.Type("\x1b[O")
+	h.WaitFor("033   [   O")
+}
+
+func TestFocusSequencesInPastesArePassedOn(t *testing.T) {
+	h := sundertest.New(t, 10, 60, multiplexer.WithShell(func() *exec.Cmd {
+		return sundertest.Command("/bin/sh", "-c", `stty raw -echo; printf 'ready\r\n'; head -c 17 | cat -v; sleep 5`)
+	}))
+	h.WaitFor("ready")
+	// a paste which contains the bytes of a focus report
+	h.Type("\x1b[200~a\x1b[Ob\x1b[201~")
+	h.WaitFor("^[[200~a^[[Ob^[[201~")
+}
+
+// shellsForFocusTests runs the given script in the first pane, and a program which exits after a key is pressed in
+// every pane after that, so focus can be moved back to the first pane
+func shellsForFocusTests(script string) func() *exec.Cmd {
+	var lock sync.Mutex
+	var panes int
+	return func() *exec.Cmd {
+		lock.Lock()
+		defer lock.Unlock()
+		panes++
+		if panes == 1 {
+			return sundertest.Command("/bin/sh", "-c", script)
+		}
+		return sundertest.Command("/bin/sh", "-c", `stty -icanon -echo min 1; printf 'second\n'; head -c 1 >/dev/null`)
+	}
+}
+
+func TestFocusEventsArePassedToPanesWhenFocusMoves(t *testing.T) {
+	h := sundertest.New(t, 10, 60, multiplexer.WithShell(shellsForFocusTests(
+		`printf '\033[?1004hready\n'; stty -icanon -echo min 1; head -c 6 | od -An -c; sleep 5`,
+	)))
+	h.WaitFor("ready")
+	h.Type("\x01v")
+	h.WaitFor("second")
+	// the second pane exits, and focus returns to the first
+	h.Type("x")
+	h.WaitFor("033   [   O 033   [   I")
+}
+
+func TestFocusEventsAreNotPassedToPanesWhichDontAskForThem(t *testing.T) {
+	h := sundertest.New(t, 10, 60, multiplexer.WithShell(shellsForFocusTests(
+		// reads end when nothing has been typed for 2 seconds
+		`stty -icanon -echo min 0 time 20; printf 'ready\n'; head -c 3 | od -An -c; echo finished; sleep 5`,
+	)))
+	h.WaitFor("ready")
+	h.Type("\x01v")
+	h.WaitFor("second")
+	h.Type("x")
+	h.WaitFor("finished")
+	if h.Screen().Contains("033") {
+		t.Fatalf("a focus event was passed to a pane which didn't ask for them, screen was:\n%s", h.Screen())
+	}
+}
+
+func TestKeysAreEncodedForApplicationKeyModes(t *testing.T) {
+	h := sundertest.New(t, 10, 60, multiplexer.WithShell(func() *exec.Cmd {
+		return sundertest.Command("/bin/sh", "-c", `printf '\033[?1h\033=ready\n'; stty -icanon -echo min 1; head -c 12 | od -An -c; sleep 5`)
+	}))
+	h.WaitFor("ready")
+	// cursor up, keypad 5 and ctrl + cursor up
+	h.Type("\x1b[A\x1bOu\x1b[1;5A")
+	h.WaitFor("033   O   A 033   O   u 033   [   1   ;   5   A")
+}
+
+func TestKeysAreEncodedForNormalKeyModes(t *testing.T) {
+	h := sundertest.New(t, 10, 60, multiplexer.WithShell(func() *exec.Cmd {
+		return sundertest.Command("/bin/sh", "-c", `printf 'ready\n'; stty -icanon -echo min 1; head -c 4 | od -An -c; sleep 5`)
+	}))
+	h.WaitFor("ready")
+	// keypad 5 and cursor up, as sent by a parent terminal in application modes
+	h.Type("\x1bOu\x1bOA")
+	h.WaitFor("5 033   [   A")
+}
+
+func TestShortcutKeyIsRecognisedWithKeyboardEnhancements(t *testing.T) {
+	h := sundertest.New(t, 10, 60)
+	h.WaitFor("$")
+	// ctrl-a as sent with the kitty keyboard protocol, then v
+	h.Type("\x1b[97;5uv")
+	h.WaitFor("┃")
+}
+
+func TestExtendedKeysAreEncodedForEachPane(t *testing.T) {
+	tests := []struct {
+		name     string
+		enable   string
+		size     int
+		expected string
+	}{
+		{"legacy", ``, 3, "002  \\r 033"},
+		{"kitty", `\033[>1u`, 13, "033   [   9   8   ;   5   u  \\r 033   [   2   7   u"},
+		{"modifyOtherKeys", `\033[>4;2m`, 12, "033   [   2   7   ;   5   ;   9   8   ~  \\r 033"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			script := fmt.Sprintf(`printf '%sready\n'; stty -icanon -icrnl -echo min 1; head -c %d | od -An -c; sleep 5`, test.enable, test.size)
+			h := sundertest.New(t, 10, 60, multiplexer.WithShell(func() *exec.Cmd {
+				return sundertest.Command("/bin/sh", "-c", script)
+			}))
+			h.WaitFor("ready")
+			// ctrl-b, enter and escape as sent with the kitty keyboard protocol
+			h.Type("\x1b[98;5u\r\x1b[27u")
+			h.WaitFor(test.expected)
+		})
+	}
+}
+
+func TestKittyFunctionalKeysAreEncodedForLegacyPanes(t *testing.T) {
+	h := sundertest.New(t, 10, 60, multiplexer.WithShell(func() *exec.Cmd {
+		return sundertest.Command("/bin/sh", "-c", `printf 'ready\n'; stty -icanon -icrnl -echo min 1; head -c 12 | od -An -c; sleep 5`)
+	}))
+	h.WaitFor("ready")
+	// media play, which has no legacy encoding, then keypad 5, keypad left, ctrl + keypad page up and keypad enter, as
+	// sent with the kitty keyboard protocol
+	h.Type("\x1b[57428u\x1b[57404u\x1b[57417u\x1b[57421;5u\x1b[57414ux")
+	h.WaitFor("5 033   [   D 033   [   5   ;   5   ~  \\r   x")
+}
+
+func TestQueriesFromPanesAreAnswered(t *testing.T) {
+	h := sundertest.New(t, 10, 60, multiplexer.WithShell(func() *exec.Cmd {
+		return sundertest.Command("/bin/sh", "-c", `stty raw -echo; printf 'ready\r\n'; head -c 1 >/dev/null; printf '\033]11;?\007\033[>q\033[6n'; head -c 42 | cat -v; sleep 5`)
+	}))
+	h.WaitFor("ready")
+	// the parent terminal reports its background colour, then a key is pressed
+	h.Type("\x1b]11;rgb:1111/2222/3333\x1b\\")
+	h.Type("x")
+	h.WaitFor(`^[]11;rgb:1111/2222/3333^G^[P>|sunder^[\^[[2;1R`)
+}
+
+func TestRepliesSplitAcrossReadsAreRecognised(t *testing.T) {
+	h := sundertest.New(t, 10, 60, multiplexer.WithShell(func() *exec.Cmd {
+		return sundertest.Command("/bin/sh", "-c", `stty raw -echo; printf 'ready\r\n'; head -c 1 >/dev/null; printf '\033]11;?\007\033[>q\033[6n'; head -c 42 | cat -v; sleep 5`)
+	}))
+	h.WaitFor("ready")
+	// replies from the parent terminal arrive in pieces, then a key is pressed
+	h.Type("\x1b[")
+	h.Type("?62;22c")
+	h.Type("\x1b]11;rgb:1111/")
+	h.Type("2222/3333\x1b")
+	h.Type("\\")
+	h.Type("x")
+	h.WaitFor(`^[]11;rgb:1111/2222/3333^G^[P>|sunder^[\^[[2;1R`)
+}
+
+func TestEscapeIsPassedOnWhenNothingFollowsIt(t *testing.T) {
+	h := sundertest.New(t, 10, 60, multiplexer.WithShell(func() *exec.Cmd {
+		return sundertest.Command("/bin/sh", "-c", `stty raw -echo; printf 'ready\r\n'; head -c 3 | cat -v; sleep 5`)
+	}))
+	h.WaitFor("ready")
+	// escape, and alt + [, which could be the start of a report
+	h.Type("\x1b")
+	h.Type("\x1b[")
+	h.WaitFor("^[^[[")
+}
+
+func TestHyperlinksAreClippedToPane(t *testing.T) {
+	h := sundertest.New(t, 10, 60)
+	h.WaitFor("$")
+	h.Type("\x01v")
+	h.WaitFor("┃$")
+	// a link which runs up to the bottom right corner of the pane, so is still open when the pane has been drawn
+	h.Run(`clear; printf '\n\n\n\n\n\n\n\n\033]8;;https://example.com\033\\%s' linklinklinklinklinklinklinkli; sleep 5`)
+	h.WaitUntil("the link is shown and the status bar is redrawn", func(s *screen.Screen) bool {
+		return s.Hyperlink(59, 8) == "https://example.com" && strings.Contains(s.Line(9), "sleep")
+	})
+	s := h.Screen()
+	for y := uint16(0); y < 10; y++ {
+		for x := uint16(0); x < 60; x++ {
+			if (y == 8 && x >= 30) || s.Hyperlink(x, y) == "" {
+				continue
+			}
+			t.Fatalf("expected no link outside the text, found %q at %d,%d on screen:\n%s", s.Hyperlink(x, y), x, y, s)
+		}
+	}
+}
+
+func TestYankingWhileThePaneIsBusy(t *testing.T) {
+	h := sundertest.New(t, 10, 60)
+	h.WaitFor("$")
+	h.Run("yes")
+	h.WaitFor("y")
+	// yanking sets the clipboard of the parent terminal while output is still being drawn, which used to deadlock
+	for start := time.Now(); time.Since(start) < time.Second; {
+		h.Type("\x01[vkkkkkkkky")
+	}
+	h.Type("\x01v")
+	h.WaitFor("┃$")
+	h.Type("typed after yanking")
+	h.WaitFor("typed after yanking")
+}
+
+func TestWidePaneNamesAreMeasuredInCells(t *testing.T) {
+	h := sundertest.New(t, 10, 60)
+	h.WaitFor("$")
+	// run a copy of sleep whose process name is made of wide characters
+	sleep := filepath.Join(t.TempDir(), "日本語")
+	h.Run(fmt.Sprintf(`cp "$(command -v sleep)" %s && %s 30`, sleep, sleep))
+	h.Type("\x01h")
+	h.WaitFor(" 日本語 ━")
+	h.WaitFor("2:sh")
+	output := h.Screen()
+	for y := uint16(0); y < 10; y++ {
+		line := output.Line(y)
+		width := 0
+		for _, r := range line {
+			width += termutil.RuneWidth(r)
+		}
+		if width > 60 {
+			t.Fatalf("expected line %d to fit in 60 columns, screen was:\n%s", y, output)
+		}
+		if strings.Contains(line, " 日本語 ━") && line != "━━ 日本語 "+strings.Repeat("━", 50) {
+			t.Fatalf("expected the divider to fill the row exactly, got %q", line)
+		}
+	}
+}
+
+func TestVerticalDividerIsLabelledWithPaneName(t *testing.T) {
+	h := sundertest.New(t, 10, 60)
+	h.WaitFor("$")
+	h.Run("sleep 30")
+	h.Type("\x01v")
+	h.WaitFor("┃$")
+	h.WaitUntil("the name of the left pane down the divider", func(s *screen.Screen) bool {
+		var label []rune
+		for y := uint16(0); y < 9; y++ {
+			line := []rune(s.Line(y))
+			if len(line) <= 29 {
+				// trailing spaces are trimmed
+				label = append(label, ' ')
+				continue
+			}
+			label = append(label, line[29])
+		}
+		return string(label) == "┃ sleep ┃"
+	})
+}
+
+func TestPanesAreRecordedAsTheyAreCreatedAndResized(t *testing.T) {
+	cfg := config.Default()
+	cfg.CaptureDir = t.TempDir()
+	cfg.RecordPanes = true
+	h := sundertest.New(t, 10, 60, multiplexer.WithConfig(cfg))
+	h.WaitFor("$")
+	h.Type("\x01r")
+	h.WaitFor("REC")
+	h.Type("\x01v")
+	h.WaitFor("┃$")
+	h.Run(`printf 'rec%sed\n' ord`)
+	h.WaitFor("recorded")
+	h.Close()
+
+	recordings, err := filepath.Glob(filepath.Join(cfg.CaptureDir, "sunder-*-pane-*.cast"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recordings) != 2 {
+		t.Fatalf("expected a recording of each pane, found %v", recordings)
+	}
+	first, err := os.ReadFile(recordings[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(first), `"width":60`) || !strings.Contains(string(first), `"r","29x9"`) {
+		t.Fatalf("expected the first pane to be recorded shrinking when it was split, recording was:\n%s", first)
+	}
+	second, err := os.ReadFile(recordings[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(second), "recorded") {
+		t.Fatalf("expected the new pane to be recorded, recording was:\n%s", second)
+	}
+}
@@ -0,0 +1,69 @@
+package multiplexer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/liamg/sunder/pkg/config"
+	"github.com/liamg/sunder/pkg/pane"
+)
+
+// how often panes are renamed after the programs running in them
+const nameInterval = time.Second
+
+// watchNames keeps the names of panes up to date as the programs running in them change, until the multiplexer
+// is closed
+func (m *Multiplexer) watchNames() {
+	ticker := time.NewTicker(nameInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.updateNames()
+		case <-m.closeChan:
+			return
+		}
+	}
+}
+
+// updateNames renames panes after their foreground programs, redrawing the status bar and dividers if anything
+// has changed
+func (m *Multiplexer) updateNames() {
+	var renamed []*pane.TerminalPane
+	for _, p := range pane.TerminalPanes(m.rootPane) {
+		if p.Exists() && p.UpdateName() {
+			renamed = append(renamed, p)
+		}
+	}
+	if len(renamed) == 0 {
+		return
+	}
+	m.updateIndicators()
+	m.renderAll()
+	for _, p := range renamed {
+		m.runHooks(config.HookWindowRenamed, m.paneEnv(p)...)
+	}
+}
+
+// paneName returns a name for the pane to show to the user, made up of its number and the program running in it
+func (m *Multiplexer) paneName(p *pane.TerminalPane) string {
+	if name := p.Name(); name != "" {
+		return fmt.Sprintf("%d:%s", m.paneNumber(p), name)
+	}
+	return fmt.Sprintf("pane %d", m.paneNumber(p))
+}
+
+// paneNumber returns the 1-indexed position of a pane in the layout, which is used to identify it to the user
+func (m *Multiplexer) paneNumber(target *pane.TerminalPane) int {
+	var number int
+	for _, p := range pane.TerminalPanes(m.rootPane) {
+		if !p.Exists() {
+			continue
+		}
+		number++
+		if p == target {
+			return number
+		}
+	}
+	return 0
+}
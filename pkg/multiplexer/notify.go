@@ -0,0 +1,64 @@
+package multiplexer
+
+import (
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/liamg/sunder/pkg/ansi"
+	"github.com/liamg/sunder/pkg/pane"
+)
+
+// notify passes a desktop notification from a pane on to the parent terminal, prefixed with the name of the pane,
+// or runs the configured notify command instead
+func (m *Multiplexer) notify(p *pane.TerminalPane, title string, body string) {
+
+	if !m.config.Notifications {
+		return
+	}
+
+	title, body = stripControl(title), stripControl(body)
+	if title == "" && body == "" {
+		return
+	}
+	name := m.paneName(p)
+
+	if m.config.NotifyCommand != "" {
+		_ = runCommand(m.config.NotifyCommand,
+			"SUNDER_PANE="+name,
+			"SUNDER_NOTIFICATION_TITLE="+title,
+			"SUNDER_NOTIFICATION_BODY="+body,
+		)
+		return
+	}
+
+	m.draw(func(w *ansi.Writer) {
+		if title == "" {
+			w.Notify(name + ": " + body)
+			return
+		}
+		w.NotifyWithTitle(name+": "+title, body)
+	})
+}
+
+// stripControl removes C0 and C1 control characters, which could otherwise end the escape sequence early when
+// forwarding text to the parent terminal
+func stripControl(text string) string {
+	return strings.Map(func(r rune) rune {
+		if r < 0x20 || (r >= 0x7f && r <= 0x9f) {
+			return -1
+		}
+		return r
+	}, text)
+}
+
+// runCommand runs a shell command in the background with the given environment variables added to our own
+func runCommand(command string, env ...string) error {
+	cmd := exec.Command("/bin/sh", "-c", command)
+	cmd.Env = append(os.Environ(), env...)
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	go func() { _ = cmd.Wait() }()
+	return nil
+}
@@ -0,0 +1,28 @@
+package multiplexer
+
+import "testing"
+
+func TestStripControl(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"plain text", "build done", "build done"},
+		{"unicode is kept", "café ✓", "café ✓"},
+		{"C0 controls", "a\x07b\x1b\\c\nd", "ab\\cd"},
+		{"delete", "a\x7fb", "ab"},
+		{"C1 string terminator", "a\u009cb", "ab"},
+		{"C1 control sequence introducer", "a\u009b31mb", "a31mb"},
+		{"first and last C1 controls", "\u0080a\u009f", "a"},
+		{"latin-1 after C1 is kept", "\u00a0é", "\u00a0é"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			if actual := stripControl(test.input); actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
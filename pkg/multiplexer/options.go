@@ -0,0 +1,105 @@
+package multiplexer
+
+import (
+	"io"
+	"os/exec"
+
+	"github.com/liamg/sunder/pkg/capability"
+	"github.com/liamg/sunder/pkg/config"
+	"github.com/liamg/sunder/pkg/pane"
+	"github.com/liamg/sunder/pkg/termutil"
+)
+
+type Option func(m *Multiplexer)
+
+// WithConfig sets the user configuration for the multiplexer
+func WithConfig(cfg *config.Config) Option {
+	return func(m *Multiplexer) {
+		m.config = cfg
+	}
+}
+
+// WithInput sets where keyboard input is read from, instead of stdin
+func WithInput(r io.Reader) Option {
+	return func(m *Multiplexer) {
+		m.stdin = r
+	}
+}
+
+// WithOutput sets where the multiplexer draws to, instead of stdout
+func WithOutput(w io.Writer) Option {
+	return func(m *Multiplexer) {
+		m.stdout = w
+	}
+}
+
+// WithSizes resizes the multiplexer whenever a size is received, instead of following the size of the terminal. If
+// no size has been set with WithSize, Start waits for the first size to be received.
+func WithSizes(sizes <-chan Size) Option {
+	return func(m *Multiplexer) {
+		m.sizes = sizes
+	}
+}
+
+// WithSize sets the size of the area the multiplexer draws into, instead of following the size of the terminal.
+// Use Resize to change it.
+func WithSize(rows uint16, cols uint16) Option {
+	return func(m *Multiplexer) {
+		m.fixedRows = rows
+		m.fixedCols = cols
+	}
+}
+
+// WithShell sets a function which creates the command to run in each new pane, instead of the user's shell
+func WithShell(shell func() *exec.Cmd) Option {
+	return func(m *Multiplexer) {
+		m.shell = shell
+	}
+}
+
+// PaneFactory creates the terminal panes run by the multiplexer. The options passed to it must be passed on to the
+// terminal, as they connect it to the multiplexer.
+type PaneFactory func(updateChan chan<- pane.Pane, options ...termutil.Option) *pane.TerminalPane
+
+// WithPaneFactory sets how new terminal panes are created, e.g. to add termutil options
+func WithPaneFactory(factory PaneFactory) Option {
+	return func(m *Multiplexer) {
+		m.paneFactory = factory
+	}
+}
+
+// RootPaneFactory creates the layout of panes the multiplexer starts with, returning its root and the pane which is
+// active first. Terminal panes in the layout must be created with newPane, which connects them to the multiplexer,
+// and containers must be given updateChan. The status bar is drawn around the root.
+type RootPaneFactory func(updateChan chan<- pane.Pane, newPane NewPaneFunc) (root pane.Pane, active pane.Pane)
+
+// NewPaneFunc creates a terminal pane connected to the multiplexer, running the shell in a terminal with any extra
+// options given
+type NewPaneFunc func(options ...termutil.Option) *pane.TerminalPane
+
+// WithRootPane sets the layout of panes the multiplexer starts with, instead of a single terminal pane
+func WithRootPane(factory RootPaneFactory) Option {
+	return func(m *Multiplexer) {
+		m.rootPaneFactory = factory
+	}
+}
+
+// WithStatusBar sets where the status bar is drawn. Use pane.Hidden to give all of the space to the panes.
+func WithStatusBar(anchor pane.Anchor) Option {
+	return func(m *Multiplexer) {
+		m.statusBarAnchor = anchor
+	}
+}
+
+func defaultPaneFactory(updateChan chan<- pane.Pane, options ...termutil.Option) *pane.TerminalPane {
+	return pane.NewTerminalPane(updateChan, termutil.New(options...))
+}
+
+// WithCapabilities sets what the terminal being drawn to can display, instead of detecting it when the multiplexer
+// starts
+func WithCapabilities(caps capability.Capabilities) Option {
+	return func(m *Multiplexer) {
+		m.capabilities = caps
+		m.fixedCapabilities = true
+	}
+}
@@ -0,0 +1,255 @@
+package multiplexer
+
+import (
+	"bytes"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/liamg/sunder/pkg/capability"
+)
+
+const modeSynchronizedOutput = 2026
+
+// bracketed pastes are passed on as they are, so pasted text is never mistaken for a report
+var (
+	pasteStart = []byte("\x1b[200~")
+	pasteEnd   = []byte("\x1b[201~")
+)
+
+// a report the parent terminal sends in reply to a query or when its state changes, which is removed from input
+// rather than passed to panes. Patterns match a whole escape sequence, so reports are never picked out of the middle
+// of another sequence.
+type report struct {
+	pattern *regexp.Regexp
+	handle  func(m *Multiplexer, match [][]byte)
+}
+
+var reports = []report{
+	// DECRPM: CSI ? mode ; status $ y
+	{regexp.MustCompile(`^\x1b\[\?(\d+);(\d+)\$y$`), (*Multiplexer).handleModeReport},
+	// DA1: CSI ? attributes c
+	{regexp.MustCompile(`^\x1b\[\?([\d;]*)c$`), func(m *Multiplexer, match [][]byte) {
+		m.updateCapabilities(func(caps *capability.Capabilities) {
+			caps.ApplyPrimaryAttributes(parseParams(match[1]))
+		})
+	}},
+	// DA2: CSI > type ; version ; options c
+	{regexp.MustCompile(`^\x1b\[>([\d;]*)c$`), func(m *Multiplexer, match [][]byte) {
+		m.updateCapabilities(func(caps *capability.Capabilities) {
+			caps.ApplySecondaryAttributes(parseParams(match[1]))
+		})
+	}},
+	// focus in and out: CSI I and CSI O
+	{regexp.MustCompile(`^\x1b\[([IO])$`), func(m *Multiplexer, match [][]byte) {
+		m.handleOuterFocus(match[1][0] == 'I')
+	}},
+	// default foreground and background colours: OSC 10 ; colour ST and OSC 11 ; colour ST
+	{regexp.MustCompile(`^\x1b\](1[01]);([^\x07\x1b]*)(?:\x07|\x1b\\)$`), func(m *Multiplexer, match [][]byte) {
+		code, _ := strconv.Atoi(string(match[1]))
+		m.outerColourLock.Lock()
+		m.outerColours[code-10] = string(match[2])
+		m.outerColourLock.Unlock()
+	}},
+	// XTVERSION: DCS > | name ST
+	{regexp.MustCompile(`^\x1bP>\|([^\x1b]*)\x1b\\$`), func(m *Multiplexer, match [][]byte) {
+		m.updateCapabilities(func(caps *capability.Capabilities) {
+			caps.ApplyVersion(string(match[1]))
+		})
+	}},
+}
+
+// detectCapabilities works out what the parent terminal can display from the environment, if we are drawing to it
+func (m *Multiplexer) detectCapabilities() {
+	if !m.fixedCapabilities && m.stdout == os.Stdout {
+		m.capabilities = capability.FromEnvironment()
+	}
+	m.stdoutWriter.SetCapabilities(m.capabilities)
+}
+
+// queryOuterTerminal asks the parent terminal which features it supports. The answers arrive as input, and are
+// picked out by handleReports. Terminals which don't understand a query ignore it, so features stay disabled.
+func (m *Multiplexer) queryOuterTerminal() {
+	m.stdoutWriter.QueryPrivateMode(modeSynchronizedOutput)
+	m.stdoutWriter.QueryDynamicColours()
+	m.stdoutWriter.QueryDeviceAttributes()
+}
+
+// outerColour returns the default foreground (10) or background (11) colour of the parent terminal, so panes can
+// answer queries for them. It is empty until the parent terminal has replied to queryOuterTerminal, or if it never
+// does.
+func (m *Multiplexer) outerColour(code int) string {
+	m.outerColourLock.Lock()
+	defer m.outerColourLock.Unlock()
+	if code < 10 || code > 11 {
+		return ""
+	}
+	return m.outerColours[code-10]
+}
+
+const (
+	// reportTimeout is how long the start of a possible report is held back waiting for the rest of it, after which
+	// it is passed on as typed input, e.g. alt + [
+	reportTimeout = 50 * time.Millisecond
+	// maxReportSize is the longest report which is held back, so a runaway sequence doesn't hold back all input
+	maxReportSize = 1024
+)
+
+// readReports acts on any reports from the parent terminal in the input, and passes the rest of the input on. A
+// report can be split across reads, so an incomplete escape sequence at the end of the input is held back and
+// joined to the next read, or passed on by itself if nothing follows it.
+func (m *Multiplexer) readReports(data []byte) {
+	m.inputLock.Lock()
+	defer m.inputLock.Unlock()
+	if m.partialTimer != nil {
+		m.partialTimer.Stop()
+		m.partialTimer = nil
+	}
+	data = append(m.partialInput, data...)
+	m.partialInput = nil
+
+	var output []byte
+	for len(data) > 0 {
+		size, complete := escapeSequenceLength(data)
+		if !complete {
+			if size > 1 && len(data) < maxReportSize {
+				m.partialInput = append([]byte(nil), data...)
+				m.partialCount++
+				count := m.partialCount
+				m.partialTimer = time.AfterFunc(reportTimeout, func() {
+					m.flushPartialInput(count)
+				})
+				break
+			}
+			// a lone escape is the escape key
+			size = len(data)
+		}
+		sequence := data[:size]
+		data = data[size:]
+		switch {
+		case bytes.Equal(sequence, pasteStart):
+			m.pastingInput = true
+		case bytes.Equal(sequence, pasteEnd):
+			m.pastingInput = false
+		case !m.pastingInput && m.handleReport(sequence):
+			continue
+		}
+		output = append(output, sequence...)
+	}
+	if len(output) > 0 {
+		_, _ = m.Write(output)
+	}
+}
+
+// flushPartialInput passes on a possible report which was held back, if nothing has been read since
+func (m *Multiplexer) flushPartialInput(count int) {
+	m.inputLock.Lock()
+	defer m.inputLock.Unlock()
+	if m.partialTimer == nil || m.partialCount != count {
+		return
+	}
+	data := m.partialInput
+	m.partialInput, m.partialTimer = nil, nil
+	_, _ = m.Write(data)
+}
+
+// handleReport acts on the sequence if it is a report from the parent terminal, returning false if it isn't
+func (m *Multiplexer) handleReport(sequence []byte) bool {
+	if len(sequence) < 3 || sequence[0] != 0x1b {
+		return false
+	}
+	for _, r := range reports {
+		if match := r.pattern.FindSubmatch(sequence); match != nil {
+			r.handle(m, match)
+			return true
+		}
+	}
+	return false
+}
+
+// escapeSequenceLength returns the length of the escape sequence, or run of text up to the next escape, at the
+// start of data. complete is false if data ends part way through a sequence, in which case the length is how much
+// of it there is.
+func escapeSequenceLength(data []byte) (size int, complete bool) {
+	if data[0] != 0x1b {
+		if next := bytes.IndexByte(data, 0x1b); next >= 0 {
+			return next, true
+		}
+		return len(data), true
+	}
+	if len(data) == 1 {
+		return 1, false
+	}
+	switch data[1] {
+	case '[':
+		// CSI: parameter and intermediate bytes, then a final byte
+		for i := 2; i < len(data); i++ {
+			switch b := data[i]; {
+			case b >= 0x40 && b <= 0x7e:
+				return i + 1, true
+			case b < 0x20 || b > 0x3f:
+				// not a valid sequence, so pass on what there is of it
+				return i, true
+			}
+		}
+		return len(data), false
+	case ']', 'P':
+		// OSC and DCS: a string ended by BEL (OSC only) or ST
+		for i := 2; i < len(data); i++ {
+			switch data[i] {
+			case 0x07:
+				if data[1] == ']' {
+					return i + 1, true
+				}
+			case 0x1b:
+				if i+1 == len(data) {
+					return len(data), false
+				}
+				if data[i+1] == '\\' {
+					return i + 2, true
+				}
+				return i, true
+			}
+		}
+		return len(data), false
+	}
+	return 2, true
+}
+
+func (m *Multiplexer) handleModeReport(match [][]byte) {
+	mode, _ := strconv.Atoi(string(match[1]))
+	status, _ := strconv.Atoi(string(match[2]))
+	// 1 and 2 are set and reset, 3 is permanently set. 0 is unrecognised, and 4 is permanently reset.
+	supported := status >= 1 && status <= 3
+	if mode == modeSynchronizedOutput {
+		m.renderLock.Lock()
+		m.frame.synchronized = supported
+		m.renderLock.Unlock()
+	}
+}
+
+// updateCapabilities changes what we think the parent terminal can display, and redraws everything to match.
+// Capabilities which were set with WithCapabilities are left alone.
+func (m *Multiplexer) updateCapabilities(update func(caps *capability.Capabilities)) {
+	if m.fixedCapabilities {
+		return
+	}
+	m.renderLock.Lock()
+	update(&m.capabilities)
+	m.stdoutWriter.SetCapabilities(m.capabilities)
+	m.renderLock.Unlock()
+	m.renderAll()
+}
+
+// parseParams splits the parameters of a report, ignoring any which aren't numbers
+func parseParams(data []byte) []int {
+	var params []int
+	for _, param := range strings.Split(string(data), ";") {
+		if value, err := strconv.Atoi(param); err == nil {
+			params = append(params, value)
+		}
+	}
+	return params
+}
@@ -0,0 +1,109 @@
+package multiplexer
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liamg/sunder/pkg/ansi"
+	"github.com/liamg/sunder/pkg/pane"
+	"github.com/liamg/sunder/pkg/termutil"
+)
+
+const (
+	benchmarkRows = 40
+	benchmarkCols = 120
+)
+
+// newBenchmarkPane creates a pane without a shell, filled with a screen of coloured output, so drawing it can be
+// measured without waiting for a program
+func newBenchmarkPane(b *testing.B) *pane.TerminalPane {
+	b.Helper()
+
+	terminal := termutil.New()
+	terminal.RunHeadless(make(chan struct{}, 1), benchmarkRows, benchmarkCols)
+	p := pane.NewTerminalPane(make(chan pane.Pane, 1), terminal)
+	b.Cleanup(p.Close)
+	p.SetActive(p)
+
+	var output strings.Builder
+	for y := 0; y < benchmarkRows; y++ {
+		if y > 0 {
+			output.WriteString("\r\n")
+		}
+		for x := 0; x < benchmarkCols; x += 8 {
+			// a new colour every few cells, as in the output of compilers, test runners and syntax highlighters
+			_, _ = fmt.Fprintf(&output, "\x1b[%dmline %02d ", 31+(x/8)%7, y)
+		}
+	}
+	// a marker in the bottom right corner, which shows when the output has been processed
+	_, _ = fmt.Fprintf(&output, "\x1b[%d;%dH#", benchmarkRows, benchmarkCols)
+	_, _ = terminal.Write([]byte(output.String()))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		terminal.Lock()
+		cell := terminal.GetActiveBuffer().GetCell(benchmarkCols-1, benchmarkRows-1)
+		processed := cell != nil && cell.Rune().Rune == '#'
+		terminal.Unlock()
+		if processed {
+			return p
+		}
+		if time.Now().After(deadline) {
+			b.Fatal("timed out waiting for the pane to process its output")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// BenchmarkRenderPane measures drawing a full pane into a frame and sending it, which happens for every frame while
+// a program is producing output
+func BenchmarkRenderPane(b *testing.B) {
+	p := newBenchmarkPane(b)
+	frame := &frameWriter{output: io.Discard}
+	w := ansi.NewWriter(frame)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p.Render(p, 0, 0, benchmarkRows, benchmarkCols, w)
+		if err := frame.Flush(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// chunkRecorder keeps a copy of each write, so a frame can be replayed in the pieces it was drawn in
+type chunkRecorder struct {
+	chunks [][]byte
+	size   int
+}
+
+func (r *chunkRecorder) Write(data []byte) (int, error) {
+	r.chunks = append(r.chunks, append([]byte(nil), data...))
+	r.size += len(data)
+	return len(data), nil
+}
+
+// BenchmarkFrameWriter measures collecting a frame from the many small writes it is drawn with, and sending it to
+// the parent terminal in a single write
+func BenchmarkFrameWriter(b *testing.B) {
+	p := newBenchmarkPane(b)
+	recorder := &chunkRecorder{}
+	p.Render(p, 0, 0, benchmarkRows, benchmarkCols, ansi.NewWriter(recorder))
+	frame := &frameWriter{output: io.Discard, synchronized: true}
+
+	b.SetBytes(int64(recorder.size))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, chunk := range recorder.chunks {
+			_, _ = frame.Write(chunk)
+		}
+		if err := frame.Flush(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package multiplexer
+
+import "github.com/liamg/sunder/pkg/overlay"
+
+// openOverlay draws the given overlay over the top of all panes, sending all input to it until it is closed
+func (m *Multiplexer) openOverlay(o overlay.Overlay) {
+	m.overlayLock.Lock()
+	m.overlay = o
+	m.overlayLock.Unlock()
+	m.renderAll()
+}
+
+func (m *Multiplexer) closeOverlay() {
+	m.overlayLock.Lock()
+	m.overlay = nil
+	m.overlayLock.Unlock()
+	m.renderAll()
+}
+
+func (m *Multiplexer) currentOverlay() overlay.Overlay {
+	m.overlayLock.Lock()
+	defer m.overlayLock.Unlock()
+	return m.overlay
+}
@@ -0,0 +1,91 @@
+package multiplexer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/liamg/sunder/pkg/logger"
+	"github.com/liamg/sunder/pkg/pane"
+)
+
+// commandPipe feeds data to the stdin of a running command
+type commandPipe struct {
+	io.WriteCloser
+	cmd *exec.Cmd
+}
+
+// how long a pipe command has to exit once its stdin is closed before it is killed
+const pipeExitTimeout = 5 * time.Second
+
+// Close closes stdin of the command and waits for it to exit, killing it if it takes too long
+func (c *commandPipe) Close() error {
+	_ = c.WriteCloser.Close()
+	exited := make(chan error, 1)
+	go func() {
+		exited <- c.cmd.Wait()
+	}()
+	select {
+	case err := <-exited:
+		return err
+	case <-time.After(pipeExitTimeout):
+		_ = c.cmd.Process.Kill()
+		return <-exited
+	}
+}
+
+// TogglePipeActivePane starts or stops copying everything written by the active pane's process to the configured
+// pipe command, or to a log file if no command is configured
+func (m *Multiplexer) TogglePipeActivePane() error {
+	active, ok := m.rootPane.FindActive().(*pane.TerminalPane)
+	if !ok {
+		return fmt.Errorf("no active terminal pane found")
+	}
+	defer m.updateIndicators()
+
+	if active.IsPiping() {
+		active.StopPipe()
+		return nil
+	}
+
+	pipe, err := m.openPipe(active)
+	if err != nil {
+		return err
+	}
+	onError := func(err error) {
+		logger.Log("Stopped piping %s: %s", m.paneName(active), err)
+		m.updateIndicators()
+	}
+	if err := active.StartPipe(pipe, onError); err != nil {
+		_ = pipe.Close()
+		return err
+	}
+	return nil
+}
+
+func (m *Multiplexer) openPipe(target *pane.TerminalPane) (io.WriteCloser, error) {
+
+	if m.config.PipeCommand == "" {
+		path := filepath.Join(
+			m.config.CaptureDir,
+			fmt.Sprintf("sunder-%s-pane-%d.log", time.Now().Format("20060102-150405"), m.paneNumber(target)),
+		)
+		return os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	}
+
+	cmd := exec.Command("/bin/sh", "-c", m.config.PipeCommand)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &commandPipe{
+		WriteCloser: stdin,
+		cmd:         cmd,
+	}, nil
+}
@@ -0,0 +1,187 @@
+package multiplexer
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/liamg/sunder/pkg/asciicast"
+	"github.com/liamg/sunder/pkg/pane"
+)
+
+// recordingWriter copies output destined for the parent terminal into the active recording, if there is one
+type recordingWriter struct {
+	m      *Multiplexer
+	writer io.Writer
+}
+
+func (r *recordingWriter) Write(data []byte) (n int, err error) {
+	r.m.recordLock.Lock()
+	if r.m.recorder != nil {
+		_, _ = r.m.recorder.Write(data)
+	}
+	r.m.recordLock.Unlock()
+	return r.writer.Write(data)
+}
+
+// ToggleRecording starts or stops recording the session in asciicast v2 format. Recordings are saved to the capture
+// directory, along with a recording of the raw output of each pane if enabled in the config.
+func (m *Multiplexer) ToggleRecording() error {
+
+	if m.IsRecording() {
+		m.stopRecording()
+		m.updateIndicators()
+		return nil
+	}
+
+	if err := m.startRecording(); err != nil {
+		return err
+	}
+	m.updateIndicators()
+
+	// redraw everything, so the recording starts with the current screen contents
+	m.renderAll()
+	return nil
+}
+
+func (m *Multiplexer) IsRecording() bool {
+	m.recordLock.Lock()
+	defer m.recordLock.Unlock()
+	return m.recorder != nil
+}
+
+func (m *Multiplexer) startRecording() error {
+
+	// before locking, as resizing records the new size with the pane lock held
+	rows, cols := m.size()
+
+	m.recordLock.Lock()
+	defer m.recordLock.Unlock()
+
+	prefix := filepath.Join(m.config.CaptureDir, fmt.Sprintf("sunder-%s", time.Now().Format("20060102-150405")))
+
+	recorder, err := createRecording(prefix+".cast", cols, rows)
+	if err != nil {
+		return err
+	}
+	m.recorder = recorder
+
+	if !m.config.RecordPanes {
+		return nil
+	}
+
+	m.recordingPrefix = prefix
+	m.paneRecordings = 0
+	m.paneRecorders = make(map[*pane.TerminalPane]*paneRecording)
+	for _, terminalPane := range pane.TerminalPanes(m.rootPane) {
+		m.recordPane(terminalPane)
+	}
+
+	return nil
+}
+
+// paneRecording is the raw recording of a pane's output, along with the size it was last recorded at
+type paneRecording struct {
+	recorder      *asciicast.Recorder
+	width, height uint16
+}
+
+// recordPane starts recording the output of a pane. The caller must hold recordLock.
+func (m *Multiplexer) recordPane(terminalPane *pane.TerminalPane) {
+	width, height := paneSize(terminalPane)
+	recorder, err := createRecording(
+		fmt.Sprintf("%s-pane-%d.cast", m.recordingPrefix, m.paneRecordings),
+		width,
+		height,
+	)
+	m.paneRecordings++
+	if err != nil {
+		return
+	}
+	terminalPane.Terminal().AddOutputPipe(recorder, nil)
+	m.paneRecorders[terminalPane] = &paneRecording{recorder: recorder, width: width, height: height}
+}
+
+// updatePaneRecordings starts recording panes created since the recording started, finishes the recordings of
+// panes which have closed, and records any change in the size of the rest
+func (m *Multiplexer) updatePaneRecordings() {
+
+	m.recordLock.Lock()
+	defer m.recordLock.Unlock()
+
+	if m.paneRecorders == nil {
+		return
+	}
+
+	current := make(map[*pane.TerminalPane]bool)
+	for _, terminalPane := range pane.TerminalPanes(m.rootPane) {
+		current[terminalPane] = true
+		recording, ok := m.paneRecorders[terminalPane]
+		if !ok {
+			m.recordPane(terminalPane)
+			continue
+		}
+		if width, height := paneSize(terminalPane); width != recording.width || height != recording.height {
+			_ = recording.recorder.Resize(width, height)
+			recording.width, recording.height = width, height
+		}
+	}
+
+	for terminalPane, recording := range m.paneRecorders {
+		if !current[terminalPane] {
+			m.finishPaneRecording(terminalPane, recording.recorder)
+			delete(m.paneRecorders, terminalPane)
+		}
+	}
+}
+
+// paneSize returns the number of columns and rows in a pane
+func paneSize(terminalPane *pane.TerminalPane) (width, height uint16) {
+	terminalPane.Terminal().Lock()
+	defer terminalPane.Terminal().Unlock()
+	buffer := terminalPane.Terminal().GetActiveBuffer()
+	return buffer.ViewWidth(), buffer.ViewHeight()
+}
+
+func createRecording(path string, width, height uint16) (*asciicast.Recorder, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	recorder, err := asciicast.NewRecorder(f, width, height, "sunder")
+	if err != nil {
+		_ = f.Close()
+		return nil, err
+	}
+	return recorder, nil
+}
+
+func (m *Multiplexer) stopRecording() {
+
+	m.recordLock.Lock()
+	defer m.recordLock.Unlock()
+
+	if m.recorder != nil {
+		_ = m.recorder.Close()
+		m.recorder = nil
+	}
+
+	for terminalPane, recording := range m.paneRecorders {
+		m.finishPaneRecording(terminalPane, recording.recorder)
+	}
+	m.paneRecorders = nil
+}
+
+// finishPaneRecording stops recording a pane, and closes the recorder once the output already queued for it has
+// been written
+func (m *Multiplexer) finishPaneRecording(terminalPane *pane.TerminalPane, recorder *asciicast.Recorder) {
+	done := terminalPane.Terminal().RemoveOutputPipe(recorder)
+	m.waitGroup.Add(1)
+	go func() {
+		defer m.waitGroup.Done()
+		<-done
+		_ = recorder.Close()
+	}()
+}
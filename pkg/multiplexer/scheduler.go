@@ -0,0 +1,92 @@
+package multiplexer
+
+import (
+	"sync"
+	"time"
+
+	"github.com/liamg/sunder/pkg/pane"
+)
+
+// renderScheduler tracks which panes need to be redrawn. However many times a pane requests a render, it is only
+// drawn once in the next frame, and frames are limited to a maximum rate while panes are busy.
+type renderScheduler struct {
+	lock  sync.Mutex
+	dirty map[pane.Pane]struct{}
+	order []pane.Pane
+	// signalled when a pane becomes dirty
+	wake chan struct{}
+}
+
+func newRenderScheduler() *renderScheduler {
+	return &renderScheduler{
+		dirty: make(map[pane.Pane]struct{}),
+		wake:  make(chan struct{}, 1),
+	}
+}
+
+// markDirty schedules a pane to be drawn in the next frame
+func (s *renderScheduler) markDirty(p pane.Pane) {
+	s.lock.Lock()
+	if _, ok := s.dirty[p]; !ok {
+		s.dirty[p] = struct{}{}
+		s.order = append(s.order, p)
+	}
+	s.lock.Unlock()
+	select {
+	case s.wake <- struct{}{}:
+	default:
+		// already awake
+	}
+}
+
+// takeDirty returns the panes which need to be drawn, in the order they were marked, and clears them
+func (s *renderScheduler) takeDirty() []pane.Pane {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	panes := s.order
+	s.order = nil
+	s.dirty = make(map[pane.Pane]struct{})
+	return panes
+}
+
+// collectUpdates marks panes as dirty as soon as they request a render. It never waits for a frame to be drawn,
+// so panes are never blocked waiting to request a render.
+func (m *Multiplexer) collectUpdates() {
+	for {
+		select {
+		case p := <-m.updateChan:
+			m.scheduler.markDirty(p)
+		case <-m.closeChan:
+			return
+		}
+	}
+}
+
+// renderFrames draws dirty panes until the multiplexer is closed. Updates which arrive while waiting for the next
+// frame are drawn together, and the last update of a burst is always drawn.
+func (m *Multiplexer) renderFrames() {
+	var interval time.Duration
+	if m.config.MaxFPS > 0 {
+		interval = time.Second / time.Duration(m.config.MaxFPS)
+	}
+	for {
+		select {
+		case <-m.scheduler.wake:
+		case <-m.closeChan:
+			return
+		}
+
+		start := time.Now()
+		m.render(m.scheduler.takeDirty()...)
+
+		if wait := interval - time.Since(start); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-m.closeChan:
+				timer.Stop()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package multiplexer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/liamg/sunder/pkg/pane"
+)
+
+// updateIndicators refreshes the list of panes and the modes highlighted in the status bar
+func (m *Multiplexer) updateIndicators() {
+	m.updatePaneNames()
+
+	var indicators []string
+	if m.synchronize {
+		var marked int
+		for _, p := range pane.TerminalPanes(m.rootPane) {
+			if p.IsMarked() && p.Exists() {
+				marked++
+			}
+		}
+		if marked > 0 {
+			indicators = append(indicators, fmt.Sprintf("SYNC %d MARKED", marked))
+		} else {
+			indicators = append(indicators, "SYNC ALL")
+		}
+	}
+	if active, ok := m.rootPane.FindActive().(*pane.TerminalPane); ok {
+		if active.CopyMode() != nil {
+			indicators = append(indicators, "COPY")
+		}
+		if active.HintMode() != nil {
+			indicators = append(indicators, "HINT")
+		}
+		if active.IsPiping() {
+			indicators = append(indicators, "PIPE")
+		}
+	}
+	indicators = append(indicators, m.alertIndicators()...)
+	if m.IsRecording() {
+		indicators = append(indicators, "REC")
+	}
+	m.statusPane.SetIndicators(indicators...)
+}
+
+// alertIndicators lists the panes flagged for each type of alert, e.g. "BELL 2,3"
+func (m *Multiplexer) alertIndicators() []string {
+	var indicators []string
+	for _, alert := range []pane.Alert{pane.AlertBell, pane.AlertActivity, pane.AlertSilence} {
+		var numbers []string
+		var number int
+		for _, p := range pane.TerminalPanes(m.rootPane) {
+			if !p.Exists() {
+				continue
+			}
+			number++
+			if p.Alerts()&alert != 0 {
+				numbers = append(numbers, strconv.Itoa(number))
+			}
+		}
+		if len(numbers) > 0 {
+			indicators = append(indicators, fmt.Sprintf("%s %s", alert, strings.Join(numbers, ",")))
+		}
+	}
+	return indicators
+}
+
+// updatePaneNames lists the panes in the status bar, with the active pane marked by a *
+func (m *Multiplexer) updatePaneNames() {
+	var names []string
+	active := m.rootPane.FindActive()
+	for _, p := range pane.TerminalPanes(m.rootPane) {
+		if !p.Exists() {
+			continue
+		}
+		name := m.paneName(p)
+		if p == active {
+			name += "*"
+		}
+		names = append(names, name)
+	}
+	m.statusPane.SetPaneNames(names...)
+}
@@ -0,0 +1,44 @@
+package multiplexer
+
+import (
+	"fmt"
+
+	"github.com/liamg/sunder/pkg/pane"
+)
+
+// ToggleSynchronize toggles sending input to multiple panes at once. If any panes are marked, only those panes
+// receive input, otherwise every terminal pane does.
+func (m *Multiplexer) ToggleSynchronize() {
+	m.synchronize = !m.synchronize
+	m.updateIndicators()
+}
+
+// ToggleMarkActivePane adds/removes the active pane to/from the subset of panes receiving synchronised input
+func (m *Multiplexer) ToggleMarkActivePane() error {
+	active, ok := m.rootPane.FindActive().(*pane.TerminalPane)
+	if !ok {
+		return fmt.Errorf("no active terminal pane found")
+	}
+	active.SetMarked(!active.IsMarked())
+	m.updateIndicators()
+	return nil
+}
+
+// synchronizedPanes returns the panes which should receive input while synchronisation is enabled
+func (m *Multiplexer) synchronizedPanes() []*pane.TerminalPane {
+	// panes which have exited can't receive input, but may not have been removed yet
+	var all, marked []*pane.TerminalPane
+	for _, p := range pane.TerminalPanes(m.rootPane) {
+		if !p.Exists() {
+			continue
+		}
+		all = append(all, p)
+		if p.IsMarked() {
+			marked = append(marked, p)
+		}
+	}
+	if len(marked) > 0 {
+		return marked
+	}
+	return all
+}
@@ -1,7 +1,15 @@
 package multiplexer
 
+import (
+	"github.com/liamg/sunder/pkg/input"
+	"github.com/liamg/sunder/pkg/pane"
+)
+
 const SunderShortcutKey = 0x1 // ctrl-a
 
+// the shortcut key as it is parsed, so it is recognised however the parent terminal encodes it
+var shortcutKey, _ = input.ParseKey([]byte{SunderShortcutKey})
+
 // Process StdIn and send it on to the active pane's process
 func (m *Multiplexer) Write(data []byte) (n int, err error) {
 
@@ -9,20 +17,65 @@ func (m *Multiplexer) Write(data []byte) (n int, err error) {
 		return 0, nil
 	}
 
-	active := m.rootPane.FindActive()
-
 	if m.inEscapeSequence {
 		m.inEscapeSequence = false
-		m.handleShortcut(data[0])
-		return len(data), active.HandleStdIn(data[1:])
-	} else if data[0] == SunderShortcutKey {
-		if len(data) > 1 {
-			m.handleShortcut(data[1])
-			return len(data), active.HandleStdIn(data[2:])
+		key, size := input.ParseKey(data)
+		m.handleShortcut(key)
+		return len(data), m.handleInput(data[size:])
+	} else if key, size := input.ParseKey(data); key == shortcutKey {
+		if len(data) > size {
+			next, nextSize := input.ParseKey(data[size:])
+			m.handleShortcut(next)
+			return len(data), m.handleInput(data[size+nextSize:])
 		} else {
 			m.inEscapeSequence = true
 		}
 	}
 
-	return len(data), active.HandleStdIn(data)
+	return len(data), m.handleInput(data)
+}
+
+// handleInput sends input to the active pane, or to all synchronised panes if synchronisation is enabled
+func (m *Multiplexer) handleInput(data []byte) error {
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	if o := m.currentOverlay(); o != nil {
+		if !o.HandleInput(data) {
+			m.closeOverlay()
+			m.updateIndicators()
+		} else {
+			m.renderAll()
+		}
+		return nil
+	}
+
+	// input goes to copy or hint mode rather than the program running in the pane, until the mode is exited
+	if active, ok := m.rootPane.FindActive().(*pane.TerminalPane); ok {
+		if copyMode := active.CopyMode(); copyMode != nil {
+			copyMode.HandleInput(data)
+			m.updateIndicators()
+			return nil
+		}
+		if hintMode := active.HintMode(); hintMode != nil {
+			hintMode.HandleInput(data)
+			m.updateIndicators()
+			return nil
+		}
+	}
+
+	if !m.synchronize {
+		return m.rootPane.FindActive().HandleStdIn(data)
+	}
+
+	// keep going if a pane fails so the others still receive the input
+	var firstErr error
+	for _, target := range m.synchronizedPanes() {
+		if err := target.HandleStdIn(data); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
 }
@@ -0,0 +1,233 @@
+package overlay
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/liamg/sunder/pkg/ansi"
+	"github.com/liamg/sunder/pkg/capability"
+	"github.com/liamg/sunder/pkg/input"
+	"github.com/liamg/sunder/pkg/termutil"
+)
+
+// Item is an entry in a chooser list
+type Item struct {
+	Label string
+	Value interface{}
+}
+
+// Chooser lists the items returned for a query typed by the user, and lets them select one
+type Chooser struct {
+	title    string
+	query    string
+	items    []Item
+	selected int
+	scroll   int
+	search   func(query string) []Item
+	onSelect func(item Item)
+	lock     sync.Mutex
+}
+
+// NewChooser creates a chooser which calls search to list items whenever the query changes, and onSelect when the
+// user selects an item
+func NewChooser(title string, search func(query string) []Item, onSelect func(item Item)) *Chooser {
+	return &Chooser{
+		title:    title,
+		search:   search,
+		onSelect: onSelect,
+	}
+}
+
+func (c *Chooser) HandleInput(data []byte) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	for len(data) > 0 {
+		key, size := input.ParseKey(data)
+		data = data[size:]
+
+		switch {
+		case key.Code == input.KeyEscape, key.IsCtrl('c'):
+			return false
+		case key.Code == input.KeyEnter:
+			if c.selected < len(c.items) {
+				item := c.items[c.selected]
+				c.lock.Unlock()
+				c.onSelect(item)
+				c.lock.Lock()
+			}
+			return false
+		case key.Code == input.KeyUp, key.IsCtrl('p'):
+			c.moveSelection(-1)
+		case key.Code == input.KeyDown, key.IsCtrl('n'):
+			c.moveSelection(1)
+		case key.Code == input.KeyPageUp:
+			c.moveSelection(-10)
+		case key.Code == input.KeyPageDown:
+			c.moveSelection(10)
+		case key.Code == input.KeyBackspace:
+			if runes := []rune(c.query); len(runes) > 0 {
+				c.query = string(runes[:len(runes)-1])
+				c.update()
+			}
+		case key.Code == input.KeyRune && key.Modifiers&(input.ModCtrl|input.ModAlt) == 0:
+			c.query += string(key.Rune)
+			c.update()
+		}
+	}
+
+	return true
+}
+
+func (c *Chooser) update() {
+	c.items = c.search(c.query)
+	c.selected = 0
+	c.scroll = 0
+}
+
+func (c *Chooser) moveSelection(delta int) {
+	c.selected += delta
+	if c.selected >= len(c.items) {
+		c.selected = len(c.items) - 1
+	}
+	if c.selected < 0 {
+		c.selected = 0
+	}
+}
+
+// Render draws the chooser as a box inset from the given area
+func (c *Chooser) Render(offsetX, offsetY, rows, cols uint16, w *ansi.Writer) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if rows < 5 || cols < 10 {
+		return
+	}
+
+	// leave a margin around the box so the panes underneath are still recognisable
+	x, y, width, height := offsetX+2, offsetY+1, cols-4, rows-2
+	listHeight := int(height) - 4
+
+	if c.selected < c.scroll {
+		c.scroll = c.selected
+	} else if c.selected >= c.scroll+listHeight {
+		c.scroll = c.selected - listHeight + 1
+	}
+
+	// fall back to reverse video and ASCII borders on terminals which can't display colours or box drawing
+	caps := w.Capabilities()
+	boxStyle, selectedStyle := "\x1b[97;44m", "\x1b[30;47m"
+	switch {
+	case caps.Colours == capability.Monochrome:
+		boxStyle, selectedStyle = "\x1b[0m", "\x1b[7m"
+	case caps.Colours < capability.Colours16:
+		boxStyle = "\x1b[37;44m"
+	}
+	horizontal, vertical, topLeft, topRight, teeLeft, teeRight, bottomLeft, bottomRight := '━', "┃", "┏", "┓", "┣", "┫", "┗", "┛"
+	if !caps.UTF8 {
+		horizontal, vertical, topLeft, topRight, teeLeft, teeRight, bottomLeft, bottomRight = '-', "|", "+", "+", "+", "+", "+", "+"
+	}
+
+	w.SetCursorVisible(false)
+	w.ResetFormatting()
+	_, _ = w.Write([]byte(boxStyle))
+
+	title := fmt.Sprintf(" %s (%d) ", c.title, len(c.items))
+	c.renderLine(x, y, width, topLeft, fitString(title, int(width)-2, horizontal, caps), topRight, w)
+	c.renderLine(x, y+1, width, vertical, fitString(" > "+c.query, int(width)-2, ' ', caps), vertical, w)
+	c.renderLine(x, y+2, width, teeLeft, fitString("", int(width)-2, horizontal, caps), teeRight, w)
+
+	for i := 0; i < listHeight; i++ {
+		index := c.scroll + i
+		label := ""
+		if index < len(c.items) {
+			label = " " + c.items[index].Label
+		}
+		line := fitString(label, int(width)-2, ' ', caps)
+		if index == c.selected && index < len(c.items) {
+			line = selectedStyle + line + boxStyle
+		}
+		c.renderLine(x, y+3+uint16(i), width, vertical, line, vertical, w)
+	}
+
+	c.renderLine(x, y+height-1, width, bottomLeft, fitString("", int(width)-2, horizontal, caps), bottomRight, w)
+
+	w.ResetFormatting()
+	cursor := 3 + stringWidth(c.query, caps)
+	if cursor > int(width)-3 {
+		cursor = int(width) - 3
+	}
+	w.MoveCursorTo(y+1, x+1+uint16(cursor))
+	w.SetCursorVisible(true)
+}
+
+func (c *Chooser) renderLine(x, y, width uint16, left, middle, right string, w *ansi.Writer) {
+	w.MoveCursorTo(y, x)
+	_, _ = w.Write([]byte(left + middle + right))
+}
+
+// fitString pads or truncates s to exactly width cells, replacing characters the terminal can't display
+func fitString(s string, width int, pad rune, caps capability.Capabilities) string {
+	runes := []rune(s)
+	total := 0
+	for i, r := range runes {
+		runes[i] = displayableRune(r, caps)
+		total += termutil.RuneWidth(runes[i])
+	}
+
+	ellipsis := ""
+	limit := width
+	if total > width {
+		ellipsis = "…"
+		if !caps.UTF8 {
+			ellipsis = "..."
+		}
+		if len([]rune(ellipsis)) < width {
+			limit = width - len([]rune(ellipsis))
+		} else {
+			ellipsis = ""
+		}
+	}
+
+	var builder strings.Builder
+	used := 0
+	for _, r := range runes {
+		size := termutil.RuneWidth(r)
+		if used+size > limit {
+			break
+		}
+		builder.WriteRune(r)
+		used += size
+	}
+	if ellipsis != "" {
+		// a wide character which didn't fit leaves a gap before the ellipsis
+		builder.WriteString(strings.Repeat(" ", limit-used))
+		builder.WriteString(ellipsis)
+		used = width
+	}
+	for ; used < width; used++ {
+		builder.WriteRune(pad)
+	}
+	return builder.String()
+}
+
+// stringWidth returns the number of cells s takes up once displayed
+func stringWidth(s string, caps capability.Capabilities) int {
+	width := 0
+	for _, r := range s {
+		width += termutil.RuneWidth(displayableRune(r, caps))
+	}
+	return width
+}
+
+// displayableRune replaces control characters with spaces, and characters the terminal can't display with '?'
+func displayableRune(r rune, caps capability.Capabilities) rune {
+	switch {
+	case r < 0x20, r >= 0x7f && r < 0xa0:
+		return ' '
+	case !caps.UTF8 && r > 0x7e:
+		return '?'
+	}
+	return r
+}
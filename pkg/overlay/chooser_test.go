@@ -0,0 +1,41 @@
+package overlay
+
+import (
+	"testing"
+
+	"github.com/liamg/sunder/pkg/capability"
+)
+
+func TestFitString(t *testing.T) {
+	ascii := capability.Full()
+	ascii.UTF8 = false
+
+	tests := []struct {
+		name     string
+		input    string
+		width    int
+		pad      rune
+		caps     capability.Capabilities
+		expected string
+	}{
+		{"padded", "abc", 6, '-', capability.Full(), "abc---"},
+		{"exact fit", "abcdef", 6, ' ', capability.Full(), "abcdef"},
+		{"truncated", "abcdefgh", 6, ' ', capability.Full(), "abcde…"},
+		{"truncated without utf-8", "abcdefgh", 6, ' ', ascii, "abc..."},
+		{"too narrow for an ascii ellipsis", "abcdefgh", 3, ' ', ascii, "abc"},
+		{"control characters", "a\tb\u009bc", 6, ' ', capability.Full(), "a b c "},
+		{"non-ascii without utf-8", "café", 6, ' ', ascii, "caf?  "},
+		{"wide characters are measured in cells", "日本", 6, ' ', capability.Full(), "日本  "},
+		{"wide character at the boundary", "ab日本語", 6, ' ', capability.Full(), "ab日 …"},
+		{"combining marks take no space", "éé", 3, ' ', capability.Full(), "éé "},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			actual := fitString(test.input, test.width, test.pad, test.caps)
+			if actual != test.expected {
+				t.Errorf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+package overlay
+
+import "github.com/liamg/sunder/pkg/ansi"
+
+// Overlay is drawn over the top of all panes, and receives all input until it is closed
+type Overlay interface {
+	// HandleInput processes input, returning false once the overlay should be closed
+	HandleInput(data []byte) bool
+	Render(offsetX, offsetY, rows, cols uint16, w *ansi.Writer)
+}
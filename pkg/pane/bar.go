@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/liamg/sunder/pkg/ansi"
+	"github.com/liamg/sunder/pkg/capability"
 )
 
 type Anchor uint8
@@ -13,6 +14,8 @@ type Anchor uint8
 const (
 	Top Anchor = iota
 	Bottom
+	// Hidden doesn't draw the status bar, giving its space to the panes
+	Hidden
 )
 
 type StatusPane struct {
@@ -21,6 +24,11 @@ type StatusPane struct {
 	closeChan  chan struct{}
 	closeOnce  sync.Once
 	anchor     Anchor
+	// indicators are highlighted in the status bar
+	indicators []string
+	// names of the terminal panes, listed in the status bar
+	paneNames     []string
+	indicatorLock sync.Mutex
 }
 
 func NewStatusPane(updateChan chan<- Pane, child Pane, anchor Anchor) *StatusPane {
@@ -58,7 +66,7 @@ func (p *StatusPane) Start(rows, cols uint16) error {
 
 	p.requestRender()
 
-	err := p.child.Start(rows-1, cols)
+	err := p.child.Start(rows-p.height(), cols)
 
 	p.requestRender()
 	p.Close()
@@ -78,16 +86,16 @@ func (p *StatusPane) Close() {
 }
 
 func (p *StatusPane) requestRender() {
+	// the multiplexer coalesces requests as soon as they arrive, so this only waits if it is very busy
 	select {
 	case p.updateChan <- p:
-	default:
-		// TODO handle this case when buffer is full and channel blocks?
+	case <-p.closeChan:
 	}
 }
 
 func (p *StatusPane) Resize(rows uint16, cols uint16) error {
 
-	_ = p.child.Resize(rows-1, cols)
+	_ = p.child.Resize(rows-p.height(), cols)
 	p.requestRender()
 	return nil
 }
@@ -99,7 +107,7 @@ func (p *StatusPane) HandleStdIn(data []byte) error {
 
 func (p *StatusPane) Render(target Pane, offsetX, offsetY, rows, cols uint16, writer *ansi.Writer) {
 
-	if p == target {
+	if p == target && p.anchor != Hidden {
 		// draw status bar
 
 		switch p.anchor {
@@ -112,13 +120,45 @@ func (p *StatusPane) Render(target Pane, offsetX, offsetY, rows, cols uint16, wr
 		writer.ClearLine()
 		writer.ResetFormatting()
 
-		// set colours
-		_, _ = writer.Write([]byte("\r\x1b[41m\x1b[97m"))
+		// set colours, falling back to reverse video if the terminal doesn't have any
+		barStyle, indicatorStyle := "\x1b[41;97m", "\x1b[30;43m"
+		caps := writer.Capabilities()
+		switch {
+		case caps.Colours == capability.Monochrome:
+			barStyle, indicatorStyle = "\x1b[7m", "\x1b[0m"
+		case caps.Colours < capability.Colours16:
+			barStyle = "\x1b[41;37m"
+		}
+		_, _ = writer.Write([]byte("\r" + barStyle))
 
-		output := fmt.Sprintf(" Sunder %s", time.Now().String())
+		output := " Sunder "
+		length := len(output)
 
-		for len(output) < int(cols) {
+		// items which don't fit are dropped, rather than letting the bar wrap onto another line
+		add := func(text string, visibleLength int) {
+			if length+visibleLength > int(cols) {
+				return
+			}
+			output += text
+			length += visibleLength
+		}
+
+		p.indicatorLock.Lock()
+		for _, name := range p.paneNames {
+			add(displayableLabel(name, caps)+" ", labelWidth(name, caps)+1)
+		}
+		for _, indicator := range p.indicators {
+			// highlight indicators in black on yellow
+			add(fmt.Sprintf("%s %s %s ", indicatorStyle, displayableLabel(indicator, caps), barStyle), labelWidth(indicator, caps)+3)
+		}
+		p.indicatorLock.Unlock()
+
+		clock := time.Now().String()
+		add(clock, len(clock))
+
+		for length < int(cols) {
 			output += " "
+			length++
 		}
 
 		_, _ = writer.Write([]byte(output))
@@ -126,20 +166,53 @@ func (p *StatusPane) Render(target Pane, offsetX, offsetY, rows, cols uint16, wr
 		return
 	}
 
+	if p == target {
+		// the status bar is hidden, so there is nothing to draw
+		return
+	}
+
 	// bump child pane down if status bar goes at the top
 	if p.anchor == Top {
 		offsetY += 1
 	}
 
-	p.child.Render(target, offsetX, offsetY, rows-1, cols, writer)
+	p.child.Render(target, offsetX, offsetY, rows-p.height(), cols, writer)
+
+}
 
+// height returns the number of rows taken up by the status bar
+func (p *StatusPane) height() uint16 {
+	if p.anchor == Hidden {
+		return 0
+	}
+	return 1
 }
 
 func (p *StatusPane) FindActive() Pane {
 	return p.child.FindActive()
 }
 
-func (p *StatusPane) Split(target Pane, mode SplitMode) bool {
+func (p *StatusPane) Children() []Pane {
+	return []Pane{p.child}
+}
+
+// SetIndicators replaces the indicators which are highlighted in the status bar, e.g. to show a mode is enabled
+func (p *StatusPane) SetIndicators(indicators ...string) {
+	p.indicatorLock.Lock()
+	p.indicators = indicators
+	p.indicatorLock.Unlock()
+	p.requestRender()
+}
+
+// SetPaneNames replaces the list of panes shown in the status bar
+func (p *StatusPane) SetPaneNames(names ...string) {
+	p.indicatorLock.Lock()
+	p.paneNames = names
+	p.indicatorLock.Unlock()
+	p.requestRender()
+}
+
+func (p *StatusPane) Split(target Pane, newPane Pane, mode SplitMode) bool {
 	splitter, ok := p.child.(Splitter)
 	if !ok {
 		return false
@@ -147,5 +220,5 @@ func (p *StatusPane) Split(target Pane, mode SplitMode) bool {
 	if target == p {
 		target = p.child
 	}
-	return splitter.Split(target, mode)
+	return splitter.Split(target, newPane, mode)
 }
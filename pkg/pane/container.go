@@ -6,14 +6,16 @@ import (
 
 	"github.com/liamg/sunder/pkg/logger"
 
-	terminal "github.com/liamg/termutil/pkg/termutil"
-
 	"github.com/liamg/sunder/pkg/ansi"
+	"github.com/liamg/sunder/pkg/capability"
+	"github.com/liamg/sunder/pkg/termutil"
 )
 
 type ContainerPane struct {
-	mode       SplitMode
-	children   []Pane
+	mode     SplitMode
+	children []Pane
+	// guards children and the size of the container, which change as panes are split and exit while rendering
+	lock       sync.Mutex
 	updateChan chan<- Pane
 	closeChan  chan struct{}
 	closeOnce  sync.Once
@@ -33,16 +35,17 @@ func NewContainerPane(updateChan chan<- Pane, mode SplitMode, children ...Pane)
 
 func (p *ContainerPane) SetActive(target Pane) {
 
-	if len(p.children) == 0 {
+	children := p.Children()
+	if len(children) == 0 {
 		return
 	}
 
 	if p == target {
-		p.children[0].SetActive(p.children[0])
+		children[0].SetActive(children[0])
 		return
 	}
 
-	for _, child := range p.children {
+	for _, child := range children {
 		child.SetActive(target)
 	}
 }
@@ -51,8 +54,11 @@ func (p *ContainerPane) Start(rows, cols uint16) error {
 
 	updateChan := make(chan struct{}, 1)
 
+	p.lock.Lock()
 	p.cols = cols
 	p.rows = rows
+	p.lock.Unlock()
+	children := p.Children()
 
 	go func() {
 		for {
@@ -66,8 +72,8 @@ func (p *ContainerPane) Start(rows, cols uint16) error {
 		}
 	}()
 
-	for i, child := range p.children {
-		_, _, w, h := p.calculateOffsetPositionForChildN(cols, rows, i)
+	for i, child := range children {
+		_, _, w, h := p.calculateOffsetPositionForChildN(len(children), cols, rows, i)
 		p.childWait.Add(1)
 		go func(c Pane, w, h uint16) {
 			_ = c.Resize(h, w)
@@ -91,6 +97,8 @@ func (p *ContainerPane) clean() {
 
 	var setNewActive bool
 
+	p.lock.Lock()
+
 	// remove inactive children
 	var filtered []Pane
 	for _, p := range p.children {
@@ -103,20 +111,25 @@ func (p *ContainerPane) clean() {
 		filtered = append(filtered, p)
 	}
 
+	changed := len(filtered) != len(p.children)
+	p.children = filtered
+	rows, cols := p.rows, p.cols
+
+	p.lock.Unlock()
+
 	if setNewActive {
 		if len(filtered) > 0 {
 			p.SetActive(filtered[len(filtered)-1])
 		}
 	}
 
-	if len(filtered) != len(p.children) {
-		p.children = filtered
-		_ = p.Resize(p.rows, p.cols)
+	if changed {
+		_ = p.Resize(rows, cols)
 	}
 }
 
 func (p *ContainerPane) Exists() bool {
-	for _, child := range p.children {
+	for _, child := range p.Children() {
 		if child.Exists() {
 			return true
 		}
@@ -126,7 +139,7 @@ func (p *ContainerPane) Exists() bool {
 
 func (p *ContainerPane) Close() {
 	p.closeOnce.Do(func() {
-		for _, child := range p.children {
+		for _, child := range p.Children() {
 			child.Close()
 		}
 		close(p.closeChan)
@@ -134,25 +147,28 @@ func (p *ContainerPane) Close() {
 }
 
 func (p *ContainerPane) requestRender() {
+	// the multiplexer coalesces requests as soon as they arrive, so this only waits if it is very busy
 	select {
 	case p.updateChan <- p:
-	default:
-		// TODO handle this case when buffer is full and channel blocks?
+	case <-p.closeChan:
 	}
 }
 
 func (p *ContainerPane) Resize(rows uint16, cols uint16) error {
 
-	for i, child := range p.children {
-		_, _, w, h := p.calculateOffsetPositionForChildN(cols, rows, i)
+	children := p.Children()
+	for i, child := range children {
+		_, _, w, h := p.calculateOffsetPositionForChildN(len(children), cols, rows, i)
 		logger.Log("Resizing child to %dx%d", w, h)
 		if err := child.Resize(h, w); err != nil {
 			return err
 		}
 	}
 
+	p.lock.Lock()
 	p.cols = cols
 	p.rows = rows
+	p.lock.Unlock()
 
 	p.requestRender()
 	return nil
@@ -169,29 +185,63 @@ func (p *ContainerPane) Render(target Pane, offsetX, offsetY, rows, cols uint16,
 
 	// TODO draw dividers
 
-	for i, child := range p.children {
+	children := p.Children()
+	for i, child := range children {
 		// recalculate offsets/sizes before rendering
-		childOffsetX, childOffsetY, w, h := p.calculateOffsetPositionForChildN(cols, rows, i)
+		childOffsetX, childOffsetY, w, h := p.calculateOffsetPositionForChildN(len(children), cols, rows, i)
 		if sendChildAsTarget {
 			target = child
 
 			// only draw border if rendering of whole container requested
 
-			if i < len(p.children)-1 {
+			if i < len(children)-1 {
 
-				writer.Write([]byte("\x1b[31m"))
+				if writer.Capabilities().Colours != capability.Monochrome {
+					writer.Write([]byte("\x1b[31m"))
+				}
 				writer.SetCursorVisible(false)
 
+				horizontalDivider, verticalDivider := "━", "┃"
+				if !writer.Capabilities().UTF8 {
+					horizontalDivider, verticalDivider = "-", "|"
+				}
+
+				// label the divider with the name of the pane above or to the left of it
+				var label []rune
+				if terminalPane, ok := child.(*TerminalPane); ok && terminalPane.Name() != "" {
+					label = []rune(" " + terminalPane.Name() + " ")
+				}
+
 				switch p.mode {
 				case Horizontal:
 					writer.MoveCursorTo(offsetY+childOffsetY+h, offsetX+childOffsetX)
-					for x := uint16(0); x < w; x++ {
-						_, _ = writer.Write([]byte("━"))
+					x := uint16(0)
+					if w > 4 {
+						// the label starts two columns in, and is cut off at the last whole character that fits
+						_, _ = writer.Write([]byte(horizontalDivider + horizontalDivider))
+						x = 2
+						for _, r := range label {
+							r = displayableRune(r, writer.Capabilities())
+							size := uint16(termutil.RuneWidth(r))
+							if x+size > w {
+								break
+							}
+							_, _ = writer.Write([]byte(string(r)))
+							x += size
+						}
+					}
+					for ; x < w; x++ {
+						_, _ = writer.Write([]byte(horizontalDivider))
 					}
 				case Vertical:
+					// the label runs down the divider, one character per row
 					for y := uint16(0); y < h; y++ {
 						writer.MoveCursorTo(offsetY+childOffsetY+y, offsetX+childOffsetX+w)
-						_, _ = writer.Write([]byte("┃"))
+						if y >= 1 && int(y-1) < len(label) && h > 2 && termutil.RuneWidth(label[y-1]) == 1 {
+							_, _ = writer.Write([]byte(string(displayableRune(label[y-1], writer.Capabilities()))))
+							continue
+						}
+						_, _ = writer.Write([]byte(verticalDivider))
 					}
 				}
 
@@ -206,7 +256,7 @@ func (p *ContainerPane) Render(target Pane, offsetX, offsetY, rows, cols uint16,
 }
 
 func (p *ContainerPane) FindActive() Pane {
-	for _, child := range p.children {
+	for _, child := range p.Children() {
 		if active := child.FindActive(); active != nil {
 			return active
 		}
@@ -214,16 +264,26 @@ func (p *ContainerPane) FindActive() Pane {
 	return nil
 }
 
-func (p *ContainerPane) calculateOffsetPositionForChildN(cols, rows uint16, childN int) (x, y, w, h uint16) {
+// Children returns the panes in the container. Panes are added and removed as they are split and exit, so the
+// returned slice is a copy.
+func (p *ContainerPane) Children() []Pane {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return append([]Pane(nil), p.children...)
+}
+
+// calculateOffsetPositionForChildN returns the position and size of child n of a container with the given number
+// of children
+func (p *ContainerPane) calculateOffsetPositionForChildN(children int, cols, rows uint16, childN int) (x, y, w, h uint16) {
 
-	if len(p.children) == 1 {
+	if children == 1 {
 		return 0, 0, cols, rows
 	}
 
 	w = cols
 	h = rows
 
-	count := uint16(len(p.children))
+	count := uint16(children)
 
 	switch p.mode { // height is affected
 	case Horizontal:
@@ -257,21 +317,33 @@ func (p *ContainerPane) calculateOffsetPositionForChildN(cols, rows uint16, chil
 	return
 }
 
-func (p *ContainerPane) Split(target Pane, mode SplitMode) bool {
-	for i, child := range p.children {
+func (p *ContainerPane) Split(target Pane, newPane Pane, mode SplitMode) bool {
+	for _, child := range p.Children() {
 		if child == target {
 
 			logger.Log("Found child to split!")
 
-			term := terminal.New()
-			termPane := NewTerminalPane(p.updateChan, term)
-			container := NewContainerPane(p.updateChan, mode, child, termPane)
+			container := NewContainerPane(p.updateChan, mode, child, newPane)
+
+			p.lock.Lock()
+			i := -1
+			for j, c := range p.children {
+				if c == child {
+					i = j
+				}
+			}
+			if i < 0 {
+				// the pane exited while it was being split
+				p.lock.Unlock()
+				return false
+			}
 
-			_, _, w, h := p.calculateOffsetPositionForChildN(p.cols, p.rows, i)
+			_, _, w, h := p.calculateOffsetPositionForChildN(len(p.children), p.cols, p.rows, i)
 
 			logger.Log("New dimensions for entire container should be %dx%d", w, h)
 
 			p.children[i] = container
+			p.lock.Unlock()
 			p.childWait.Add(1)
 
 			go func() {
@@ -281,12 +353,12 @@ func (p *ContainerPane) Split(target Pane, mode SplitMode) bool {
 			}()
 
 			// make new pane the active
-			container.SetActive(termPane)
+			container.SetActive(newPane)
 
 			return true
 		} else if splitter, ok := child.(Splitter); ok {
 			_ = splitter
-			if splitter.Split(target, mode) {
+			if splitter.Split(target, newPane, mode) {
 				return true
 			}
 		}
@@ -0,0 +1,551 @@
+package pane
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/liamg/sunder/pkg/ansi"
+	"github.com/liamg/sunder/pkg/input"
+	"github.com/liamg/sunder/pkg/termutil"
+)
+
+// colours used to draw copy mode over the terminal content
+var (
+	matchAttr        = termutil.CellAttributes{}.WithColours("30", "43")
+	currentMatchAttr = termutil.CellAttributes{}.WithColours("30", "45")
+	selectionAttr    = termutil.CellAttributes{}.WithColours("30", "47")
+	copyModeInfoAttr = termutil.CellAttributes{}.WithColours("30", "43")
+)
+
+type search struct {
+	pattern  string
+	regex    bool
+	backward bool
+	matches  []Match
+	current  int
+	err      error
+}
+
+// CopyMode lets the user move around the scrollback of a terminal pane, search it and yank text from it
+type CopyMode struct {
+	pane   *TerminalPane
+	onYank func(data []byte)
+	// text yanked while handling input, which is passed to onYank once the lock is released
+	yanked []byte
+	lock   sync.Mutex
+	// index of the first line in the viewport, counting from the oldest line in the buffer
+	top int
+	// cursor position, relative to the viewport
+	cursorX int
+	cursorY int
+	// selection anchor, with line counted from the oldest line in the buffer
+	selecting     bool
+	selectionX    int
+	selectionLine int
+	search        search
+	// set while the user is typing a search pattern
+	prompting bool
+	// position to return to if the search prompt is cancelled
+	promptTop     int
+	promptCursorX int
+	promptCursorY int
+}
+
+// EnterCopyMode switches the pane into copy mode. onYank is called with the selected text when the user yanks.
+func (p *TerminalPane) EnterCopyMode(onYank func(data []byte)) *CopyMode {
+	p.copyLock.Lock()
+	defer p.copyLock.Unlock()
+	if p.copyMode != nil {
+		return p.copyMode
+	}
+	p.hintMode = nil
+
+	p.terminal.Lock()
+	buffer := p.terminal.GetActiveBuffer()
+	top := buffer.Height() - int(buffer.ViewHeight())
+	if top < 0 {
+		top = 0
+	}
+	p.copyMode = &CopyMode{
+		pane:    p,
+		onYank:  onYank,
+		top:     top,
+		cursorX: int(buffer.CursorColumn()),
+		cursorY: int(buffer.CursorLine()),
+	}
+	p.terminal.Unlock()
+	p.requestRender()
+	return p.copyMode
+}
+
+// ExitCopyMode returns the pane to displaying the live terminal
+func (p *TerminalPane) ExitCopyMode() {
+	p.copyLock.Lock()
+	p.copyMode = nil
+	p.copyLock.Unlock()
+	p.requestRender()
+}
+
+// CopyMode returns the copy mode of the pane, or nil if it is not in copy mode
+func (p *TerminalPane) CopyMode() *CopyMode {
+	p.copyLock.Lock()
+	defer p.copyLock.Unlock()
+	return p.copyMode
+}
+
+// buffer returns the buffer being browsed. The terminal must be locked while it is used.
+func (c *CopyMode) buffer() *termutil.Buffer {
+	return c.pane.terminal.GetActiveBuffer()
+}
+
+// StartSearch opens the search prompt
+func (c *CopyMode) StartSearch(backward bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.startSearch(backward)
+	c.pane.requestRender()
+}
+
+func (c *CopyMode) startSearch(backward bool) {
+	c.prompting = true
+	c.search = search{
+		backward: backward,
+		regex:    c.search.regex,
+		current:  -1,
+	}
+	c.promptTop, c.promptCursorX, c.promptCursorY = c.top, c.cursorX, c.cursorY
+}
+
+// HandleInput processes keys typed while in copy mode
+func (c *CopyMode) HandleInput(data []byte) {
+	c.handleInput(data)
+	// onYank is called after releasing the lock, as it may need to wait for a render to finish
+	c.lock.Lock()
+	yanked := c.yanked
+	c.yanked = nil
+	c.lock.Unlock()
+	if yanked != nil && c.onYank != nil {
+		c.onYank(yanked)
+	}
+}
+
+func (c *CopyMode) handleInput(data []byte) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if !c.handleKeys(data) {
+		c.pane.ExitCopyMode()
+		return
+	}
+	c.pane.requestRender()
+}
+
+// handleKeys handles each key in data, returning false if copy mode should exit
+func (c *CopyMode) handleKeys(data []byte) bool {
+	c.pane.terminal.Lock()
+	defer c.pane.terminal.Unlock()
+	for len(data) > 0 {
+		key, size := input.ParseKey(data)
+		data = data[size:]
+		if c.prompting {
+			c.handlePromptKey(key)
+		} else if !c.handleKey(key) {
+			return false
+		}
+	}
+	return true
+}
+
+// handleKey handles a key press outside of the search prompt, returning false if copy mode should exit
+func (c *CopyMode) handleKey(key input.Key) bool {
+
+	height := int(c.buffer().ViewHeight())
+
+	switch {
+	case key.Code == input.KeyUp, key.Code == input.KeyRune && key.Modifiers == 0 && key.Rune == 'k':
+		c.moveCursor(0, -1)
+	case key.Code == input.KeyDown, key.Code == input.KeyRune && key.Modifiers == 0 && key.Rune == 'j':
+		c.moveCursor(0, 1)
+	case key.Code == input.KeyLeft, key.Code == input.KeyRune && key.Modifiers == 0 && key.Rune == 'h':
+		c.moveCursor(-1, 0)
+	case key.Code == input.KeyRight, key.Code == input.KeyRune && key.Modifiers == 0 && key.Rune == 'l':
+		c.moveCursor(1, 0)
+	case key.Code == input.KeyPageUp, key.IsCtrl('b'):
+		c.scroll(-height)
+	case key.Code == input.KeyPageDown, key.IsCtrl('f'):
+		c.scroll(height)
+	case key.IsCtrl('u'):
+		c.scroll(-height / 2)
+	case key.IsCtrl('d'):
+		c.scroll(height / 2)
+	case key.Code == input.KeyHome:
+		c.jumpTo(0, 0)
+	case key.Code == input.KeyEnd:
+		c.jumpTo(c.buffer().Height()-1, 0)
+	case key.Code == input.KeyEscape:
+		if !c.selecting {
+			return false
+		}
+		c.selecting = false
+	case key.Code == input.KeyEnter:
+		c.yank()
+		return false
+	case key.Code != input.KeyRune || key.Modifiers != 0:
+	case key.Rune == 'q':
+		return false
+	case key.Rune == 'g':
+		c.jumpTo(0, 0)
+	case key.Rune == 'G':
+		c.jumpTo(c.buffer().Height()-1, 0)
+	case key.Rune == '0':
+		c.cursorX = 0
+	case key.Rune == '$':
+		c.cursorX = len([]rune(c.pane.lineText(c.top + c.cursorY)))
+		if c.cursorX > 0 {
+			c.cursorX--
+		}
+	case key.Rune == 'v', key.Rune == ' ':
+		c.selecting = !c.selecting
+		c.selectionX, c.selectionLine = c.cursorX, c.top+c.cursorY
+	case key.Rune == 'y':
+		c.yank()
+		return false
+	case key.Rune == '/':
+		c.startSearch(false)
+	case key.Rune == '?':
+		c.startSearch(true)
+	case key.Rune == 'n':
+		c.nextMatch(c.search.backward)
+	case key.Rune == 'N':
+		c.nextMatch(!c.search.backward)
+	}
+
+	return true
+}
+
+func (c *CopyMode) handlePromptKey(key input.Key) {
+	switch {
+	case key.Code == input.KeyEnter:
+		c.prompting = false
+	case key.Code == input.KeyEscape:
+		c.prompting = false
+		c.search = search{current: -1, regex: c.search.regex}
+		c.top, c.cursorX, c.cursorY = c.promptTop, c.promptCursorX, c.promptCursorY
+	case key.Code == input.KeyBackspace:
+		if c.search.pattern == "" {
+			c.prompting = false
+			return
+		}
+		runes := []rune(c.search.pattern)
+		c.search.pattern = string(runes[:len(runes)-1])
+		c.updateSearch()
+	case key.IsCtrl('r'):
+		c.search.regex = !c.search.regex
+		c.updateSearch()
+	case key.Code == input.KeyRune && key.Modifiers&(input.ModCtrl|input.ModAlt) == 0:
+		c.search.pattern += string(key.Rune)
+		c.updateSearch()
+	}
+}
+
+// updateSearch finds all matches for the current pattern, and jumps to the nearest one in the search direction from
+// where the search started
+func (c *CopyMode) updateSearch() {
+
+	c.top, c.cursorX, c.cursorY = c.promptTop, c.promptCursorX, c.promptCursorY
+	c.runSearch()
+	c.nextMatch(c.search.backward)
+}
+
+func (c *CopyMode) runSearch() {
+
+	c.search.matches = nil
+	c.search.current = -1
+	c.search.err = nil
+
+	if c.search.pattern == "" {
+		return
+	}
+
+	expr, err := CompileSearch(c.search.pattern, c.search.regex)
+	if err != nil {
+		c.search.err = err
+		return
+	}
+
+	c.search.matches = c.pane.search(expr)
+}
+
+// ShowMatch highlights all matches of the given search pattern, and jumps to the match at the given position
+func (c *CopyMode) ShowMatch(pattern string, regex bool, match Match) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.pane.terminal.Lock()
+
+	c.prompting = false
+	c.search = search{
+		pattern: pattern,
+		regex:   regex,
+	}
+	c.runSearch()
+	for i, m := range c.search.matches {
+		if m == match {
+			c.search.current = i
+		}
+	}
+	c.jumpTo(match.Line, match.Start)
+	c.pane.terminal.Unlock()
+	c.pane.requestRender()
+}
+
+// nextMatch jumps to the next match after (or before, if backward) the cursor, wrapping around the buffer
+func (c *CopyMode) nextMatch(backward bool) {
+	if len(c.search.matches) == 0 {
+		return
+	}
+	line, col := c.top+c.cursorY, c.cursorX
+	next := -1
+	if backward {
+		for i := len(c.search.matches) - 1; i >= 0; i-- {
+			m := c.search.matches[i]
+			if m.Line < line || (m.Line == line && m.Start < col) {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			next = len(c.search.matches) - 1
+		}
+	} else {
+		for i, m := range c.search.matches {
+			if m.Line > line || (m.Line == line && m.Start > col) {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			next = 0
+		}
+	}
+	c.search.current = next
+	match := c.search.matches[next]
+	c.jumpTo(match.Line, match.Start)
+}
+
+// jumpTo moves the cursor to the given line and column, scrolling the viewport to centre the line if it is not visible
+func (c *CopyMode) jumpTo(line int, col int) {
+	height := int(c.buffer().ViewHeight())
+	if line < c.top || line >= c.top+height {
+		c.top = line - height/2
+		c.clampTop()
+	}
+	c.cursorY = line - c.top
+	c.cursorX = col
+}
+
+func (c *CopyMode) moveCursor(dx, dy int) {
+	buffer := c.buffer()
+	c.cursorX += dx
+	if c.cursorX < 0 {
+		c.cursorX = 0
+	} else if c.cursorX >= int(buffer.ViewWidth()) {
+		c.cursorX = int(buffer.ViewWidth()) - 1
+	}
+	c.cursorY += dy
+	if c.cursorY < 0 {
+		c.scroll(c.cursorY)
+		c.cursorY = 0
+	} else if c.cursorY >= int(buffer.ViewHeight()) {
+		c.scroll(c.cursorY - int(buffer.ViewHeight()) + 1)
+		c.cursorY = int(buffer.ViewHeight()) - 1
+	}
+}
+
+func (c *CopyMode) scroll(lines int) {
+	c.top += lines
+	c.clampTop()
+}
+
+func (c *CopyMode) clampTop() {
+	buffer := c.buffer()
+	max := buffer.Height() - int(buffer.ViewHeight())
+	if c.top > max {
+		c.top = max
+	}
+	if c.top < 0 {
+		c.top = 0
+	}
+}
+
+// selectionBounds returns the selection start and end positions, in order
+func (c *CopyMode) selectionBounds() (startLine, startX, endLine, endX int) {
+	startLine, startX = c.selectionLine, c.selectionX
+	endLine, endX = c.top+c.cursorY, c.cursorX
+	if endLine < startLine || (endLine == startLine && endX < startX) {
+		startLine, startX, endLine, endX = endLine, endX, startLine, startX
+	}
+	return
+}
+
+func (c *CopyMode) isSelected(line, x int) bool {
+	if !c.selecting {
+		return false
+	}
+	startLine, startX, endLine, endX := c.selectionBounds()
+	if line < startLine || line > endLine {
+		return false
+	}
+	if line == startLine && x < startX {
+		return false
+	}
+	if line == endLine && x > endX {
+		return false
+	}
+	return true
+}
+
+// yank stores the selected text (or the line under the cursor if nothing is selected) for the yank handler
+func (c *CopyMode) yank() {
+
+	startLine, startX, endLine, endX := c.top+c.cursorY, 0, c.top+c.cursorY, -1
+	if c.selecting {
+		startLine, startX, endLine, endX = c.selectionBounds()
+	}
+
+	var output strings.Builder
+	for i := startLine; i <= endLine; i++ {
+		runes := []rune(c.pane.lineText(i))
+		from, to := 0, len(runes)
+		if i == startLine && startX < to {
+			from = startX
+		}
+		if i == endLine && endX >= 0 && endX+1 < to {
+			to = endX + 1
+		}
+		if from < to {
+			output.WriteString(strings.TrimRight(VisibleText(string(runes[from:to])), " "))
+		}
+		// wrapped lines are a continuation of the previous line, so don't break between them
+		if i < endLine {
+			if next := c.buffer().Line(i + 1); next == nil || !next.IsWrapped() {
+				output.WriteString("\n")
+			}
+		}
+	}
+
+	c.yanked = []byte(output.String())
+}
+
+func (c *CopyMode) render(offsetX, offsetY, rows, cols uint16, w *ansi.Writer) {
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.pane.terminal.Lock()
+	defer c.pane.terminal.Unlock()
+
+	buffer := c.buffer()
+
+	w.SetCursorVisible(false)
+	w.ResetFormatting()
+
+	var lastCellAttr termutil.CellAttributes
+
+	visibleMatches := c.matchesBetween(c.top, c.top+int(rows))
+
+	for y := uint16(0); y < rows; y++ {
+		lineIndex := c.top + int(y)
+		line := buffer.Line(lineIndex)
+		w.MoveCursorTo(offsetY+y, offsetX)
+		row := lineCells(line)
+		for x := uint16(0); x < cols; x++ {
+			text, width, attr := glyphAt(row, x, cols)
+			if c.isSelected(lineIndex, int(x)) {
+				attr = selectionAttr
+			} else if matchIndex := c.matchAt(visibleMatches, lineIndex, int(x)); matchIndex >= 0 {
+				attr = matchAttr
+				if matchIndex == c.search.current {
+					attr = currentMatchAttr
+				}
+			}
+			writeCell(w, text, width, attr, &lastCellAttr)
+			if width > 1 {
+				x++
+				if x+1 < cols {
+					w.MoveCursorTo(offsetY+y, offsetX+x+1)
+				}
+			}
+		}
+		endRow(w, &lastCellAttr)
+	}
+
+	// show the search prompt on the bottom line, otherwise show our position in the scrollback in the top right
+	if c.prompting {
+		prompt := "/"
+		if c.search.backward {
+			prompt = "?"
+		}
+		prompt += c.search.pattern
+		if c.search.regex {
+			prompt = "(regex) " + prompt
+		}
+		if c.search.err != nil {
+			prompt += " [invalid]"
+		} else if c.search.pattern != "" {
+			prompt += fmt.Sprintf(" [%d matches]", len(c.search.matches))
+		}
+		c.renderInfo(prompt, offsetX, offsetY+rows-1, cols, false, w, &lastCellAttr)
+		return
+	}
+
+	info := fmt.Sprintf("[%d/%d]", c.top, buffer.Height()-int(buffer.ViewHeight()))
+	if c.search.current >= 0 {
+		info = fmt.Sprintf("[match %d/%d] %s", c.search.current+1, len(c.search.matches), info)
+	}
+	c.renderInfo(info, offsetX, offsetY, cols, true, w, &lastCellAttr)
+
+	w.MoveCursorTo(offsetY+uint16(c.cursorY), offsetX+uint16(c.cursorX))
+	w.SetCursorVisible(true)
+}
+
+func (c *CopyMode) renderInfo(text string, offsetX, y, cols uint16, alignRight bool, w *ansi.Writer, lastCellAttr *termutil.CellAttributes) {
+	runes := []rune(text)
+	if len(runes) > int(cols) {
+		runes = runes[len(runes)-int(cols):]
+	}
+	x := offsetX
+	if alignRight {
+		x += cols - uint16(len(runes))
+	}
+	w.MoveCursorTo(y, x)
+	for _, r := range runes {
+		writeCell(w, string(r), 1, copyModeInfoAttr, lastCellAttr)
+	}
+	if !alignRight {
+		w.MoveCursorTo(y, x+uint16(len(runes)))
+		w.SetCursorVisible(true)
+	}
+}
+
+// matchesBetween returns the indexes of search matches from line start up to (but excluding) line end
+func (c *CopyMode) matchesBetween(start, end int) []int {
+	first := sort.Search(len(c.search.matches), func(i int) bool {
+		return c.search.matches[i].Line >= start
+	})
+	var indexes []int
+	for i := first; i < len(c.search.matches) && c.search.matches[i].Line < end; i++ {
+		indexes = append(indexes, i)
+	}
+	return indexes
+}
+
+// matchAt returns the index of the search match covering the given cell, or -1 if there isn't one
+func (c *CopyMode) matchAt(indexes []int, line, x int) int {
+	for _, i := range indexes {
+		m := c.search.matches[i]
+		if m.Line == line && x >= m.Start && x < m.End {
+			return i
+		}
+	}
+	return -1
+}
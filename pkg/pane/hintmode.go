@@ -0,0 +1,260 @@
+package pane
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/liamg/sunder/pkg/ansi"
+	"github.com/liamg/sunder/pkg/input"
+	"github.com/liamg/sunder/pkg/termutil"
+)
+
+// keys used for hint labels, in order of preference
+const hintAlphabet = "asdfghjklqwertyuiopzxcvbnm"
+
+// colours used to draw hint mode over the terminal content
+var (
+	hintAttr      = termutil.CellAttributes{}.WithColours("33", "")
+	hintLabelAttr = termutil.CellAttributes{}.WithColours("30", "43")
+)
+
+// Hint is a match in the visible area of a pane which can be selected by typing its label
+type Hint struct {
+	Label string
+	Text  string
+	// Line is the row of the viewport containing the hint
+	Line  int
+	Start int
+	End   int
+}
+
+// HintMode labels interesting text such as URLs and hashes in a terminal pane, so it can be selected with a few keys
+type HintMode struct {
+	pane     *TerminalPane
+	onSelect func(text string, open bool)
+	lock     sync.Mutex
+	// index of the first line in the viewport, counting from the oldest line in the buffer
+	top   int
+	hints []Hint
+	typed string
+}
+
+// EnterHintMode labels every match of the given patterns in the visible area of the pane. onSelect is called with
+// the text of the chosen hint, and open is set if the label was typed in upper case.
+func (p *TerminalPane) EnterHintMode(patterns []*regexp.Regexp, onSelect func(text string, open bool)) (*HintMode, error) {
+	p.copyLock.Lock()
+	defer p.copyLock.Unlock()
+	if p.copyMode != nil {
+		return nil, fmt.Errorf("pane is in copy mode")
+	}
+	if p.hintMode != nil {
+		return p.hintMode, nil
+	}
+
+	p.terminal.Lock()
+	buffer := p.terminal.GetActiveBuffer()
+	top := buffer.Height() - int(buffer.ViewHeight())
+	if top < 0 {
+		top = 0
+	}
+	hints := p.findHints(patterns, top, int(buffer.ViewHeight()))
+	p.terminal.Unlock()
+
+	if len(hints) == 0 {
+		return nil, fmt.Errorf("no hints found")
+	}
+
+	p.hintMode = &HintMode{
+		pane:     p,
+		onSelect: onSelect,
+		top:      top,
+		hints:    hints,
+	}
+	p.requestRender()
+	return p.hintMode, nil
+}
+
+// ExitHintMode returns the pane to displaying the live terminal
+func (p *TerminalPane) ExitHintMode() {
+	p.copyLock.Lock()
+	p.hintMode = nil
+	p.copyLock.Unlock()
+	p.requestRender()
+}
+
+// HintMode returns the hint mode of the pane, or nil if it is not in hint mode
+func (p *TerminalPane) HintMode() *HintMode {
+	p.copyLock.Lock()
+	defer p.copyLock.Unlock()
+	return p.hintMode
+}
+
+// findHints matches patterns against the visible lines of the pane. Where matches overlap, the one starting first
+// wins, or the longest if they start together. The terminal must be locked.
+func (p *TerminalPane) findHints(patterns []*regexp.Regexp, top int, rows int) []Hint {
+	var hints []Hint
+	for y := 0; y < rows; y++ {
+		text := p.lineText(top + y)
+		var lineHints []Hint
+		for _, expr := range patterns {
+			for _, loc := range expr.FindAllStringIndex(text, -1) {
+				if loc[0] == loc[1] {
+					continue
+				}
+				lineHints = append(lineHints, Hint{
+					Text:  VisibleText(text[loc[0]:loc[1]]),
+					Line:  y,
+					Start: len([]rune(text[:loc[0]])),
+					End:   len([]rune(text[:loc[1]])),
+				})
+			}
+		}
+		sort.Slice(lineHints, func(i, j int) bool {
+			if lineHints[i].Start == lineHints[j].Start {
+				return lineHints[i].End > lineHints[j].End
+			}
+			return lineHints[i].Start < lineHints[j].Start
+		})
+		end := -1
+		for _, hint := range lineHints {
+			if hint.Start < end {
+				continue
+			}
+			hints = append(hints, hint)
+			end = hint.End
+		}
+	}
+
+	// label from the bottom up, so the most recent output gets the first labels
+	labels := hintLabels(len(hints))
+	for i := range hints {
+		hints[i].Label = labels[len(hints)-1-i]
+	}
+	return hints
+}
+
+// hintLabels generates n labels of equal length, so no label is a prefix of another
+func hintLabels(n int) []string {
+	labels := []string{""}
+	// a single hint still needs a key to select it
+	for len(labels) < n || labels[0] == "" {
+		var next []string
+		for _, prefix := range labels {
+			for _, r := range hintAlphabet {
+				next = append(next, prefix+string(r))
+			}
+		}
+		labels = next
+	}
+	return labels[:n]
+}
+
+// HandleInput processes keys typed while in hint mode
+func (h *HintMode) HandleInput(data []byte) {
+	// onSelect is called after releasing the lock, as it may need to wait for a render to finish
+	hint, open := h.handleInput(data)
+	if hint != nil && h.onSelect != nil {
+		h.onSelect(hint.Text, open)
+	}
+}
+
+func (h *HintMode) handleInput(data []byte) (*Hint, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	for len(data) > 0 {
+		key, size := input.ParseKey(data)
+		data = data[size:]
+		switch {
+		case key.Code == input.KeyEscape, key.IsCtrl('c'), key.IsCtrl('g'):
+			h.pane.ExitHintMode()
+			return nil, false
+		case key.Code == input.KeyBackspace:
+			if h.typed != "" {
+				h.typed = h.typed[:len(h.typed)-1]
+			}
+		case key.Code == input.KeyRune && strings.ContainsRune(hintAlphabet, unicode.ToLower(key.Rune)):
+			h.typed += string(unicode.ToLower(key.Rune))
+			if hint := h.lookup(); hint != nil {
+				h.pane.ExitHintMode()
+				return hint, unicode.IsUpper(key.Rune)
+			}
+			if len(h.visibleHints()) == 0 {
+				h.typed = ""
+			}
+		}
+	}
+	h.pane.requestRender()
+	return nil, false
+}
+
+func (h *HintMode) lookup() *Hint {
+	for i, hint := range h.hints {
+		if hint.Label == h.typed {
+			return &h.hints[i]
+		}
+	}
+	return nil
+}
+
+// visibleHints returns the hints with labels starting with what has been typed so far
+func (h *HintMode) visibleHints() []Hint {
+	var hints []Hint
+	for _, hint := range h.hints {
+		if strings.HasPrefix(hint.Label, h.typed) {
+			hints = append(hints, hint)
+		}
+	}
+	return hints
+}
+
+func (h *HintMode) render(offsetX, offsetY, rows, cols uint16, w *ansi.Writer) {
+
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.pane.terminal.Lock()
+	defer h.pane.terminal.Unlock()
+
+	buffer := h.pane.terminal.GetActiveBuffer()
+
+	w.SetCursorVisible(false)
+	w.ResetFormatting()
+
+	var lastCellAttr termutil.CellAttributes
+
+	hints := h.visibleHints()
+
+	for y := uint16(0); y < rows; y++ {
+		line := buffer.Line(h.top + int(y))
+		w.MoveCursorTo(offsetY+y, offsetX)
+		row := lineCells(line)
+		for x := uint16(0); x < cols; x++ {
+			text, width, attr := glyphAt(row, x, cols)
+			for _, hint := range hints {
+				if hint.Line != int(y) || int(x) < hint.Start || int(x) >= hint.End {
+					continue
+				}
+				// the label covers the start of the match, without the part which has already been typed
+				label := []rune(hint.Label[len(h.typed):])
+				if offset := int(x) - hint.Start; offset < len(label) {
+					text, width = string(label[offset]), 1
+					attr = hintLabelAttr
+				} else {
+					attr = hintAttr
+				}
+				break
+			}
+			writeCell(w, text, width, attr, &lastCellAttr)
+			if width > 1 {
+				x++
+				if x+1 < cols {
+					w.MoveCursorTo(offsetY+y, offsetX+x+1)
+				}
+			}
+		}
+		endRow(w, &lastCellAttr)
+	}
+}
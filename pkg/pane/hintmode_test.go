@@ -0,0 +1,107 @@
+package pane
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liamg/sunder/pkg/config"
+	"github.com/liamg/sunder/pkg/termutil"
+)
+
+func TestHintLabels(t *testing.T) {
+	for _, n := range []int{0, 1, 5, len(hintAlphabet), len(hintAlphabet) + 1, 100, len(hintAlphabet) * len(hintAlphabet)} {
+		labels := hintLabels(n)
+		if len(labels) != n {
+			t.Fatalf("expected %d labels, got %d", n, len(labels))
+		}
+		seen := make(map[string]bool)
+		for _, label := range labels {
+			if label == "" {
+				t.Fatalf("expected no empty labels for %d hints", n)
+			}
+			if seen[label] {
+				t.Fatalf("label %q is used twice for %d hints", label, n)
+			}
+			seen[label] = true
+		}
+		for _, label := range labels {
+			for _, other := range labels {
+				if label != other && strings.HasPrefix(other, label) {
+					t.Fatalf("label %q is a prefix of %q for %d hints", label, other, n)
+				}
+			}
+		}
+	}
+}
+
+// newHintPane creates a pane without a shell, showing text on its first line
+func newHintPane(t *testing.T, text string) *TerminalPane {
+	t.Helper()
+
+	terminal := termutil.New()
+	terminal.RunHeadless(make(chan struct{}, 1), 5, 80)
+	p := NewTerminalPane(make(chan Pane, 1), terminal)
+	t.Cleanup(p.Close)
+
+	// a marker at the start of the second line shows when the text has been processed
+	_, _ = terminal.Write([]byte(text + "\r\n#"))
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		terminal.Lock()
+		cell := terminal.GetActiveBuffer().GetCell(0, 1)
+		processed := cell != nil && cell.Rune().Rune == '#'
+		terminal.Unlock()
+		if processed {
+			return p
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the pane to process its output")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestDefaultHintPatterns(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     string
+		expected []Hint
+	}{
+		{"nothing to hint", "nothing to see here", nil},
+		{"url", "see https://example.com/docs?page=2 for more", []Hint{{Label: "a", Text: "https://example.com/docs?page=2", Start: 4, End: 35}}},
+		{"url at the end of a sentence", "see https://example.com.", []Hint{{Label: "a", Text: "https://example.com", Start: 4, End: 23}}},
+		{"url in brackets", "(https://example.com/a)", []Hint{{Label: "a", Text: "https://example.com/a", Start: 1, End: 22}}},
+		{"path with line", "main.go:42 undefined", []Hint{{Label: "a", Text: "main.go:42", Start: 0, End: 10}}},
+		{"path with line and column", "./pkg/pane/hintmode.go:120:7: error", []Hint{{Label: "a", Text: "./pkg/pane/hintmode.go:120:7", Start: 0, End: 28}}},
+		{"uuid wins over the hashes inside it", "id 123e4567-e89b-12d3-a456-426614174000", []Hint{{Label: "a", Text: "123e4567-e89b-12d3-a456-426614174000", Start: 3, End: 39}}},
+		{"ip address and port", "listening on 192.168.1.10:8080", []Hint{{Label: "a", Text: "192.168.1.10:8080", Start: 13, End: 30}}},
+		{"short hash", "commit 3f2a9c1 fixed it", []Hint{{Label: "a", Text: "3f2a9c1", Start: 7, End: 14}}},
+		{"full hash", "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3", []Hint{{Label: "a", Text: "a94a8fe5ccb19ba61c4c0873d391e987982fbbd3", Start: 0, End: 40}}},
+		{"too short for a hash", "abc123 deadbee", []Hint{{Label: "a", Text: "deadbee", Start: 7, End: 14}}},
+		{"hex inside a word is not a hash", "xdeadbeef", nil},
+		{"later hints get the first labels", "3f2a9c1 https://a.io", []Hint{
+			{Label: "s", Text: "3f2a9c1", Start: 0, End: 7},
+			{Label: "a", Text: "https://a.io", Start: 8, End: 20},
+		}},
+		{"positions are in cells after wide characters", "日本 3f2a9c1", []Hint{{Label: "a", Text: "3f2a9c1", Start: 5, End: 12}}},
+	}
+	patterns := config.Default().HintPatterns
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			p := newHintPane(t, test.line)
+			p.terminal.Lock()
+			hints := p.findHints(patterns, 0, 1)
+			p.terminal.Unlock()
+			if len(hints) != len(test.expected) {
+				t.Fatalf("expected hints %+v, got %+v", test.expected, hints)
+			}
+			for i := range hints {
+				if hints[i] != test.expected[i] {
+					t.Fatalf("expected hints %+v, got %+v", test.expected, hints)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package pane
+
+import (
+	"time"
+)
+
+// alertQueue is the number of alerts which can be waiting for the handler before more are dropped
+const alertQueue = 16
+
+// Alert is raised when something happens in a pane which isn't being looked at
+type Alert uint8
+
+const (
+	// AlertActivity is raised when the program in the pane writes output
+	AlertActivity Alert = 1 << iota
+	// AlertSilence is raised when the program in the pane has not written output for a while
+	AlertSilence
+	// AlertBell is raised when the program in the pane rings the bell
+	AlertBell
+)
+
+func (a Alert) String() string {
+	switch a {
+	case AlertActivity:
+		return "ACTIVITY"
+	case AlertSilence:
+		return "SILENCE"
+	case AlertBell:
+		return "BELL"
+	}
+	return "ALERT"
+}
+
+// activityWriter receives a copy of the output of a pane's program, so the pane knows when it was last active
+type activityWriter struct {
+	pane *TerminalPane
+}
+
+func (w *activityWriter) Write(data []byte) (int, error) {
+	w.pane.alertLock.Lock()
+	w.pane.lastOutput = time.Now()
+	w.pane.silent = false
+	w.pane.alertLock.Unlock()
+	w.pane.raiseAlert(AlertActivity)
+	return len(data), nil
+}
+
+// Monitor enables the given alerts for the pane, calling handler whenever one is raised. Alerts are only raised for
+// panes which are not active, and only once until they are cleared by the pane becoming active.
+func (p *TerminalPane) Monitor(alerts Alert, handler func(alert Alert)) {
+	p.alertLock.Lock()
+	defer p.alertLock.Unlock()
+	p.monitor = alerts
+	p.onAlert = handler
+}
+
+// Bell raises a bell alert. When the active pane rings the bell the handler is still called, so the bell can be
+// passed on to the user, but the pane is not flagged.
+func (p *TerminalPane) Bell() {
+	if p.isActive() {
+		p.alertLock.Lock()
+		monitored := p.monitor&AlertBell != 0
+		p.alertLock.Unlock()
+		if monitored {
+			p.dispatchAlert(AlertBell)
+		}
+		return
+	}
+	p.raiseAlert(AlertBell)
+}
+
+// CheckSilence raises a silence alert if the program in the pane has not written any output for the given duration
+func (p *TerminalPane) CheckSilence(duration time.Duration) {
+	p.alertLock.Lock()
+	silent := !p.silent && time.Since(p.lastOutput) >= duration
+	if silent {
+		p.silent = true
+	}
+	p.alertLock.Unlock()
+	if silent {
+		p.raiseAlert(AlertSilence)
+	}
+}
+
+// Alerts returns the alerts raised since the pane was last active
+func (p *TerminalPane) Alerts() Alert {
+	p.alertLock.Lock()
+	defer p.alertLock.Unlock()
+	return p.alerts
+}
+
+// ClearAlerts removes all alerts from the pane
+func (p *TerminalPane) ClearAlerts() {
+	p.alertLock.Lock()
+	defer p.alertLock.Unlock()
+	p.alerts = 0
+}
+
+func (p *TerminalPane) raiseAlert(alert Alert) {
+	if p.isActive() {
+		return
+	}
+	p.alertLock.Lock()
+	raised := p.monitor&alert != 0 && p.alerts&alert == 0
+	if raised {
+		p.alerts |= alert
+	}
+	p.alertLock.Unlock()
+	if raised {
+		p.dispatchAlert(alert)
+	}
+}
+
+// dispatchAlert queues an alert for the handler. The handler is called from its own goroutine, as it draws to the
+// parent terminal, and alerts are raised while the pane's output is being processed or drawn. Alerts are dropped
+// if the handler falls too far behind, e.g. when a program rings the bell over and over.
+func (p *TerminalPane) dispatchAlert(alert Alert) {
+	select {
+	case p.alertChan <- alert:
+	default:
+	}
+}
+
+// dispatchAlerts calls the handler with queued alerts until the pane is closed
+func (p *TerminalPane) dispatchAlerts() {
+	for {
+		select {
+		case alert := <-p.alertChan:
+			p.alertLock.Lock()
+			handler := p.onAlert
+			p.alertLock.Unlock()
+			if handler != nil {
+				handler(alert)
+			}
+		case <-p.closeChan:
+			return
+		}
+	}
+}
@@ -23,5 +23,25 @@ type Pane interface {
 }
 
 type Splitter interface {
-	Split(target Pane, mode SplitMode) bool
+	Split(target Pane, newPane Pane, mode SplitMode) bool
+}
+
+// Parent is implemented by panes which contain other panes
+type Parent interface {
+	Children() []Pane
+}
+
+// TerminalPanes returns every terminal pane within the tree starting at the given pane
+func TerminalPanes(p Pane) []*TerminalPane {
+	switch actual := p.(type) {
+	case *TerminalPane:
+		return []*TerminalPane{actual}
+	case Parent:
+		var panes []*TerminalPane
+		for _, child := range actual.Children() {
+			panes = append(panes, TerminalPanes(child)...)
+		}
+		return panes
+	}
+	return nil
 }
@@ -0,0 +1,16 @@
+package pane
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// processName returns the name of a running process
+func processName(pid int) (string, error) {
+	comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(comm)), nil
+}
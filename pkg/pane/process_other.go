@@ -0,0 +1,21 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package pane
+
+import (
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// processName returns the name of a running process. There is no /proc to read it from, so it is asked for from ps.
+func processName(pid int) (string, error) {
+	output, err := exec.Command("ps", "-o", "comm=", "-p", strconv.Itoa(pid)).Output()
+	if err != nil {
+		return "", err
+	}
+	// some systems give the full path of the program, and a leading dash for login shells
+	return strings.TrimPrefix(filepath.Base(strings.TrimSpace(string(output))), "-"), nil
+}
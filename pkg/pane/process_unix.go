@@ -0,0 +1,30 @@
+//go:build !windows
+// +build !windows
+
+package pane
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// foregroundProcessName returns the name of the process group leader in the foreground of the terminal, which is
+// the program the user is currently interacting with
+func foregroundProcessName(pty *os.File) (string, error) {
+	conn, err := pty.SyscallConn()
+	if err != nil {
+		return "", err
+	}
+	var pgrp int32
+	var errno syscall.Errno
+	if err := conn.Control(func(fd uintptr) {
+		_, _, errno = syscall.Syscall(syscall.SYS_IOCTL, fd, syscall.TIOCGPGRP, uintptr(unsafe.Pointer(&pgrp)))
+	}); err != nil {
+		return "", err
+	}
+	if errno != 0 {
+		return "", errno
+	}
+	return processName(int(pgrp))
+}
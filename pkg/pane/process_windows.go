@@ -0,0 +1,10 @@
+package pane
+
+import (
+	"fmt"
+	"os"
+)
+
+func foregroundProcessName(pty *os.File) (string, error) {
+	return "", fmt.Errorf("not supported on windows")
+}
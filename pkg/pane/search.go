@@ -0,0 +1,115 @@
+package pane
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Match is the location of a search match within the buffer of a terminal pane
+type Match struct {
+	// Line is the index of the line containing the match, counting from the oldest line in the buffer
+	Line int
+	// Start is the first cell of the match
+	Start int
+	// End is the cell after the last cell of the match
+	End int
+}
+
+// CompileSearch compiles a search pattern, which is matched literally unless regex is set. Matching is case
+// insensitive unless the pattern contains upper case characters.
+func CompileSearch(pattern string, regex bool) (*regexp.Regexp, error) {
+	expr := pattern
+	if !regex {
+		expr = regexp.QuoteMeta(expr)
+	}
+	if !hasUpper(pattern) {
+		expr = "(?i)" + expr
+	}
+	return regexp.Compile(expr)
+}
+
+func hasUpper(s string) bool {
+	for _, r := range s {
+		if unicode.IsUpper(r) {
+			return true
+		}
+	}
+	return false
+}
+
+// Search finds every match of expr in the active buffer of the pane, including the scrollback
+func (p *TerminalPane) Search(expr *regexp.Regexp) []Match {
+	p.terminal.Lock()
+	defer p.terminal.Unlock()
+	return p.search(expr)
+}
+
+// search finds every match of expr in the active buffer. The terminal must be locked.
+func (p *TerminalPane) search(expr *regexp.Regexp) []Match {
+	var matches []Match
+	buffer := p.terminal.GetActiveBuffer()
+	for i := 0; i < buffer.Height(); i++ {
+		matches = append(matches, MatchLine(expr, i, p.lineText(i))...)
+	}
+	return matches
+}
+
+// MatchLine finds every match of expr in the text of a line, as returned by LineText
+func MatchLine(expr *regexp.Regexp, line int, text string) []Match {
+	var matches []Match
+	for _, loc := range expr.FindAllStringIndex(text, -1) {
+		if loc[0] == loc[1] {
+			continue
+		}
+		matches = append(matches, Match{
+			Line:  line,
+			Start: len([]rune(text[:loc[0]])),
+			End:   len([]rune(text[:loc[1]])),
+		})
+	}
+	return matches
+}
+
+// Lines returns the text of every line in the active buffer, counting from the oldest line, as LineText does
+func (p *TerminalPane) Lines() []string {
+	p.terminal.Lock()
+	defer p.terminal.Unlock()
+	lines := make([]string, p.terminal.GetActiveBuffer().Height())
+	for i := range lines {
+		lines[i] = p.lineText(i)
+	}
+	return lines
+}
+
+// LineText returns the text of a line in the active buffer, counting from the oldest line, with one rune per cell.
+// The right half of a wide character is a null byte, so runes line up with columns; use VisibleText to remove them.
+func (p *TerminalPane) LineText(index int) string {
+	p.terminal.Lock()
+	defer p.terminal.Unlock()
+	return p.lineText(index)
+}
+
+// lineText returns the text of a line in the active buffer. The terminal must be locked.
+func (p *TerminalPane) lineText(index int) string {
+	line := p.terminal.GetActiveBuffer().Line(index)
+	if line == nil {
+		return ""
+	}
+	runes := make([]rune, len(line.Cells()))
+	for i, cell := range line.Cells() {
+		runes[i] = cell.Rune().Rune
+		if cell.IsContinuation() {
+			runes[i] = 0
+		} else if runes[i] < 0x20 {
+			runes[i] = ' '
+		}
+	}
+	return string(runes)
+}
+
+// VisibleText converts text returned by LineText back into the text it shows, removing the right halves of wide
+// characters
+func VisibleText(text string) string {
+	return strings.Replace(text, "\x00", "", -1)
+}
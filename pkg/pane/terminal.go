@@ -1,12 +1,18 @@
 package pane
 
 import (
+	"fmt"
+	"io"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/liamg/sunder/pkg/input"
 	"github.com/liamg/sunder/pkg/logger"
-	"github.com/liamg/termutil/pkg/termutil"
+	"github.com/liamg/sunder/pkg/termutil"
 
 	"github.com/liamg/sunder/pkg/ansi"
+	"github.com/liamg/sunder/pkg/capability"
 )
 
 type TerminalPane struct {
@@ -16,21 +22,65 @@ type TerminalPane struct {
 	active     bool
 	closeChan  chan struct{}
 	closeOnce  sync.Once
-	startLock  sync.Mutex
-	started    bool
+	// guards exists, active and marked, which are read while rendering
+	stateLock sync.Mutex
+	startLock sync.Mutex
+	started   bool
+	marked    bool
+	pipe      io.WriteCloser
+	pipeLock  sync.Mutex
+	copyMode  *CopyMode
+	hintMode  *HintMode
+	copyLock  sync.Mutex
+	// alerts raised while the pane is not active
+	alerts     Alert
+	monitor    Alert
+	onAlert    func(alert Alert)
+	alertChan  chan Alert
+	lastOutput time.Time
+	silent     bool
+	alertLock  sync.Mutex
+	onExit     func(exitCode int)
+	// name of the program running in the foreground of the pane
+	name     string
+	nameLock sync.Mutex
+	// rewrites keys for the key modes the program has chosen
+	keys input.Encoder
+	// where the cursor was when the pane was last drawn, relative to the pane, which is restored while a
+	// synchronized update holds back drawing. Only used by Render, which is never called concurrently
+	drawnCursorX       uint16
+	drawnCursorY       uint16
+	drawnCursorVisible bool
 }
 
 func NewTerminalPane(updateChan chan<- Pane, term *termutil.Terminal) *TerminalPane {
-	return &TerminalPane{
+	p := &TerminalPane{
 		terminal:   term,
 		updateChan: updateChan,
 		closeChan:  make(chan struct{}),
 		exists:     true,
+		lastOutput: time.Now(),
+		alertChan:  make(chan Alert, alertQueue),
 	}
+	term.AddOutputPipe(&activityWriter{pane: p}, nil)
+	go p.dispatchAlerts()
+	return p
 }
 
 func (p *TerminalPane) SetActive(target Pane) {
-	p.active = p == target
+	active := p == target
+	p.stateLock.Lock()
+	p.active = active
+	p.stateLock.Unlock()
+	if active {
+		p.ClearAlerts()
+	}
+}
+
+func (p *TerminalPane) isActive() bool {
+	p.stateLock.Lock()
+	defer p.stateLock.Unlock()
+	return p.active
 }
 
 func (p *TerminalPane) Start(rows, cols uint16) error {
@@ -60,30 +110,114 @@ func (p *TerminalPane) Start(rows, cols uint16) error {
 	if err := p.terminal.Run(updateChan, rows, cols); err != nil {
 		return err
 	}
+	if p.onExit != nil {
+		p.onExit(p.terminal.ExitCode())
+	}
 	p.requestRender()
 	p.Close()
 	return nil
 }
 
+// SetExitHandler sets a function to be called with the exit status of the shell when it exits, before the pane
+// is closed
+func (p *TerminalPane) SetExitHandler(handler func(exitCode int)) {
+	p.onExit = handler
+}
+
 func (p *TerminalPane) Exists() bool {
 	if p == nil {
 		return false
 	}
+	p.stateLock.Lock()
+	defer p.stateLock.Unlock()
 	return p.exists
 }
 
 func (p *TerminalPane) Close() {
 	p.closeOnce.Do(func() {
 		close(p.closeChan)
+		p.StopPipe()
+		p.terminal.Close()
+		p.stateLock.Lock()
 		p.exists = false
+		p.stateLock.Unlock()
 	})
 }
 
+// StartPipe copies everything written by the child process to w, until StopPipe is called or the pane is closed.
+// If w can't keep up or returns an error it is closed and onError, if it isn't nil, is called with the reason.
+func (p *TerminalPane) StartPipe(w io.WriteCloser, onError func(err error)) error {
+	p.pipeLock.Lock()
+	defer p.pipeLock.Unlock()
+	if p.pipe != nil {
+		return fmt.Errorf("pane output is already being piped")
+	}
+	p.pipe = w
+	p.terminal.AddOutputPipe(w, func(err error) {
+		p.pipeLock.Lock()
+		if p.pipe == w {
+			p.pipe = nil
+		}
+		p.pipeLock.Unlock()
+		closePipe(w, nil)
+		if onError != nil {
+			onError(err)
+		}
+	})
+	return nil
+}
+
+// StopPipe stops copying the output of the child process. The writer passed to StartPipe is closed in the
+// background once any output which is still queued for it has been written.
+func (p *TerminalPane) StopPipe() {
+	p.pipeLock.Lock()
+	defer p.pipeLock.Unlock()
+	if p.pipe == nil {
+		return
+	}
+	closePipe(p.pipe, p.terminal.RemoveOutputPipe(p.pipe))
+	p.pipe = nil
+}
+
+// closingPipes tracks pipes which have been stopped but are still being closed, across every pane
+var closingPipes sync.WaitGroup
+
+// WaitForPipes waits for every pipe which has been stopped to finish writing its output and close, including the
+// pipes of panes which have since been removed
+func WaitForPipes() {
+	closingPipes.Wait()
+}
+
+// closePipe closes w once done is closed, without waiting for it
+func closePipe(w io.Closer, done <-chan struct{}) {
+	closingPipes.Add(1)
+	go func() {
+		defer closingPipes.Done()
+		if done != nil {
+			<-done
+		}
+		if err := w.Close(); err != nil {
+			logger.Log("Failed to close pane pipe: %s", err)
+		}
+	}()
+}
+
+func (p *TerminalPane) IsPiping() bool {
+	p.pipeLock.Lock()
+	defer p.pipeLock.Unlock()
+	return p.pipe != nil
+}
+
+// ReportFocus tells the program running in the pane that it has gained or lost focus, if it has asked to be told
+func (p *TerminalPane) ReportFocus(focused bool) {
+	p.terminal.ReportFocus(focused)
+}
+
 func (p *TerminalPane) requestRender() {
+	// the multiplexer coalesces requests as soon as they arrive, so this only waits if it is very busy
 	select {
 	case p.updateChan <- p:
-	default:
-		// TODO handle this case when buffer is full and channel blocks?
+	case <-p.closeChan:
 	}
 }
 
@@ -100,10 +234,62 @@ func (p *TerminalPane) Resize(rows uint16, cols uint16) error {
 }
 
 func (p *TerminalPane) HandleStdIn(data []byte) error {
-	_, err := p.terminal.Pty().Write(data)
+	pty := p.terminal.Pty()
+	if pty == nil {
+		return fmt.Errorf("terminal is not running")
+	}
+	applicationCursorKeys, applicationKeypad := p.terminal.KeyModes()
+	_, err := pty.Write(p.keys.Encode(data, input.KeyModes{
+		ApplicationCursorKeys: applicationCursorKeys,
+		ApplicationKeypad:     applicationKeypad,
+		KeyboardFlags:         p.terminal.KeyboardFlags(),
+		ModifyOtherKeys:       p.terminal.ModifyOtherKeys(),
+	}))
 	return err
 }
 
+// Terminal returns the terminal emulator running in the pane
+func (p *TerminalPane) Terminal() *termutil.Terminal {
+	return p.terminal
+}
+
+// UpdateName names the pane after the program running in its foreground, falling back to the title set by the
+// program if the foreground process can't be determined. It returns true if the name has changed.
+func (p *TerminalPane) UpdateName() bool {
+	var name string
+	if pty := p.terminal.Pty(); pty != nil {
+		name, _ = foregroundProcessName(pty)
+	}
+	if name == "" {
+		name = p.terminal.GetTitle()
+	}
+	p.nameLock.Lock()
+	defer p.nameLock.Unlock()
+	changed := name != p.name
+	p.name = name
+	return changed
+}
+
+// Name returns the name of the pane as of the last call to UpdateName
+func (p *TerminalPane) Name() string {
+	p.nameLock.Lock()
+	defer p.nameLock.Unlock()
+	return p.name
+}
+
+// SetMarked marks the pane, e.g. to include it in a subset of panes receiving synchronised input
+func (p *TerminalPane) SetMarked(marked bool) {
+	p.stateLock.Lock()
+	defer p.stateLock.Unlock()
+	p.marked = marked
+}
+
+func (p *TerminalPane) IsMarked() bool {
+	p.stateLock.Lock()
+	defer p.stateLock.Unlock()
+	return p.marked
+}
+
 func (p *TerminalPane) Render(target Pane, offsetX, offsetY, rows, cols uint16, w *ansi.Writer) {
 
 	if p != target {
@@ -114,13 +300,47 @@ func (p *TerminalPane) Render(target Pane, offsetX, offsetY, rows, cols uint16,
 		return
 	}
 
+	if copyMode := p.CopyMode(); copyMode != nil {
+		copyMode.render(offsetX, offsetY, rows, cols, w)
+		return
+	}
+
+	if hintMode := p.HintMode(); hintMode != nil {
+		hintMode.render(offsetX, offsetY, rows, cols, w)
+		return
+	}
+
+	// the program is part way through redrawing, so wait for it to finish rather than showing half of the update.
+	// The cursor is still put back where it was last drawn, as drawing other panes moves it
+	if !p.terminal.InSynchronizedUpdate() && !p.renderCells(offsetX, offsetY, rows, cols, w) {
+		return
+	}
+
+	// only reposition the cursor for the active pane
+	if p.isActive() {
+		// move cursor back
+		w.MoveCursorTo(offsetY+p.drawnCursorY, offsetX+p.drawnCursorX)
+		// panes which haven't chosen a cursor style get the default, rather than the style of the last active pane
+		w.SetCursorStyle(p.terminal.CursorStyle())
+		w.SetCursorVisible(p.drawnCursorVisible)
+	}
+
+}
+
+// renderCells draws the cells of the active buffer, and notes where its cursor is. It returns false if there is no
+// buffer to draw
+func (p *TerminalPane) renderCells(offsetX, offsetY, rows, cols uint16, w *ansi.Writer) bool {
+	p.terminal.Lock()
+	defer p.terminal.Unlock()
+
 	buffer := p.terminal.GetActiveBuffer()
 	if buffer == nil {
-		return
+		return false
 	}
 
 	// grab cursor to restore afterwards - could use ansi code and let parent terminal handle this?
-	cursorX, cursorY := buffer.CursorColumn(), buffer.CursorLine()
+	p.drawnCursorX, p.drawnCursorY = buffer.CursorColumn(), buffer.CursorLine()
+	p.drawnCursorVisible = buffer.IsCursorVisible()
 
 	w.SetCursorVisible(false)
 	w.ResetFormatting()
@@ -128,54 +348,123 @@ func (p *TerminalPane) Render(target Pane, offsetX, offsetY, rows, cols uint16,
 	// replace mode!
 	_, _ = w.Write([]byte("\x1b[?4l"))
 
-	cursorX += offsetX
-	cursorY += offsetY
-
 	var lastCellAttr termutil.CellAttributes
 
 	for y := uint16(0); y < rows; y++ {
+		w.MoveCursorTo(offsetY+y, offsetX)
+		row := func(x uint16) *termutil.Cell {
+			return buffer.GetCell(x, y)
+		}
 		for x := uint16(0); x < cols; x++ {
-			cell := buffer.GetCell(x, y)
-
-			w.MoveCursorTo(offsetY+y, offsetX+x)
+			// TODO if glyph + attr are the same as last render, skip this step
+			text, width, attr := glyphAt(row, x, cols)
+			writeCell(w, text, width, attr, &lastCellAttr)
+			if width > 1 {
+				x++
+				// make sure we agree with the terminal about where the cursor is, in case it measured the character
+				// differently
+				if x+1 < cols {
+					w.MoveCursorTo(offsetY+y, offsetX+x+1)
+				}
+			}
+		}
+		endRow(w, &lastCellAttr)
+	}
+	return true
+}
 
-			if cell != nil {
-				measuredRune := cell.Rune()
-				// TODO if rune + attr are the same as last render, skip this step
-				// TODO render diffs more efficiently, this is very basic/slow atm
+// writeCell writes the text of a cell to the terminal, prefixed with any SGR and OSC 8 sequences required to change
+// from the attributes of the last cell written
+func writeCell(w *ansi.Writer, text string, width int, attr termutil.CellAttributes, lastCellAttr *termutil.CellAttributes) {
+	caps := w.Capabilities()
+	attr = attr.Downsample(caps)
+	sgr := attr.GetHyperlinkDiffANSI(*lastCellAttr) + attr.GetDiffANSI(*lastCellAttr)
+	*lastCellAttr = attr
+	_, _ = w.Write([]byte(sgr + displayableText(text, width, caps)))
+}
 
-				// TODO can we remove this? safely replaces control characters/null bytes with spaces
-				if measuredRune.Rune < 0x20 {
-					measuredRune.Rune = 0x20
-				}
+// endRow ends any hyperlink started by the last cell written, so it doesn't cover whatever is drawn beyond the edge
+// of the pane
+func endRow(w *ansi.Writer, lastCellAttr *termutil.CellAttributes) {
+	if lastCellAttr.Hyperlink() == nil {
+		return
+	}
+	end := lastCellAttr.WithHyperlink(nil)
+	_, _ = w.Write([]byte(end.GetHyperlinkDiffANSI(*lastCellAttr)))
+	*lastCellAttr = end
+}
 
-				//if measuredRune.Width > 0 {
-				sgr := cell.Attr().GetDiffANSI(lastCellAttr)
-				_, _ = w.Write([]byte(sgr + string(measuredRune.Rune)))
-				lastCellAttr = cell.Attr()
-				//}
-			} else {
-				attr := termutil.CellAttributes{}
-				sgr := attr.GetDiffANSI(lastCellAttr)
-				lastCellAttr = attr
-				_, _ = w.Write([]byte(sgr))
-				_, _ = w.Write([]byte{0x20})
+// glyphAt returns the text to draw for the cell in column x, the number of columns it covers and its attributes.
+// cell returns the cell in a column, or nil if it is empty. The right half of a wide character is drawn along with
+// the left half, so if it is reached on its own the left half is missing, and a space is drawn. Wide characters
+// which would straddle the edge of the area being drawn are clipped to a space.
+func glyphAt(cell func(x uint16) *termutil.Cell, x, cols uint16) (string, int, termutil.CellAttributes) {
+	current := cell(x)
+	switch {
+	case current == nil:
+		return " ", 1, termutil.CellAttributes{}
+	case current.IsContinuation():
+		return " ", 1, current.Attr()
+	case current.Rune().Width > 1:
+		if x+1 < cols {
+			if next := cell(x + 1); next != nil && next.IsContinuation() {
+				return current.String(), 2, current.Attr()
 			}
 		}
+		return " ", 1, current.Attr()
 	}
+	return current.String(), 1, current.Attr()
+}
 
-	// only reposition the cursor for the active pane
-	if p.active {
-		// move cursor back
-		//logger.Log("Moving cursor to %d, %d", cursorX, cursorY)
-		w.MoveCursorTo(cursorY, cursorX)
-		w.SetCursorVisible(buffer.IsCursorVisible())
+// lineCells returns a function which returns the cell in a column of a line, for use with glyphAt
+func lineCells(line *termutil.Line) func(x uint16) *termutil.Cell {
+	return func(x uint16) *termutil.Cell {
+		if line == nil || int(x) >= len(line.Cells()) {
+			return nil
+		}
+		return &line.Cells()[x]
+	}
+}
+
+// displayableText replaces characters the terminal can't display, keeping the same width
+func displayableText(text string, width int, caps capability.Capabilities) string {
+	if caps.UTF8 {
+		return text
+	}
+	for _, r := range text {
+		if r > 0x7e {
+			return strings.Repeat("?", width)
+		}
 	}
+	return text
+}
 
+// displayableRune replaces characters the terminal can't display
+func displayableRune(r rune, caps capability.Capabilities) rune {
+	if !caps.UTF8 && r > 0x7e {
+		return '?'
+	}
+	return r
+}
+
+// displayableLabel replaces each character of a label which the terminal can't display
+func displayableLabel(label string, caps capability.Capabilities) string {
+	return strings.Map(func(r rune) rune {
+		return displayableRune(r, caps)
+	}, label)
+}
+
+// labelWidth returns the number of columns a label takes up once it is displayable
+func labelWidth(label string, caps capability.Capabilities) int {
+	width := 0
+	for _, r := range label {
+		width += termutil.RuneWidth(displayableRune(r, caps))
+	}
+	return width
 }
 
 func (p *TerminalPane) FindActive() Pane {
-	if !p.active {
+	if !p.isActive() {
 		return nil
 	}
 	return p
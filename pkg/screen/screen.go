@@ -0,0 +1,114 @@
+package screen
+
+import (
+	"strings"
+
+	"github.com/liamg/sunder/pkg/termutil"
+)
+
+// Screen is a headless virtual terminal. Output written to it is interpreted in the background, and the resulting
+// screen can be inspected, e.g. to check what the multiplexer has drawn.
+type Screen struct {
+	terminal *termutil.Terminal
+}
+
+// New creates a virtual screen of the given size
+func New(rows, cols uint16) *Screen {
+	terminal := termutil.New()
+	terminal.RunHeadless(make(chan struct{}, 1), rows, cols)
+	return &Screen{
+		terminal: terminal,
+	}
+}
+
+// Write passes output to the virtual terminal. It is processed asynchronously, so may not be visible straight away.
+func (s *Screen) Write(data []byte) (int, error) {
+	return s.terminal.Write(data)
+}
+
+// Resize changes the size of the screen
+func (s *Screen) Resize(rows, cols uint16) {
+	_ = s.terminal.SetSize(rows, cols)
+}
+
+// Size returns the number of rows and columns on the screen
+func (s *Screen) Size() (rows uint16, cols uint16) {
+	s.terminal.Lock()
+	defer s.terminal.Unlock()
+	buffer := s.terminal.GetActiveBuffer()
+	return buffer.ViewHeight(), buffer.ViewWidth()
+}
+
+// Line returns the text on a row of the screen, with trailing spaces removed
+func (s *Screen) Line(y uint16) string {
+	s.terminal.Lock()
+	defer s.terminal.Unlock()
+	return s.line(y)
+}
+
+// line returns the text on a row of the screen. The terminal must be locked.
+func (s *Screen) line(y uint16) string {
+	buffer := s.terminal.GetActiveBuffer()
+	var line strings.Builder
+	for x := uint16(0); x < buffer.ViewWidth(); x++ {
+		if cell := buffer.GetCell(x, y); cell != nil {
+			line.WriteString(cell.String())
+		} else {
+			line.WriteString(" ")
+		}
+	}
+	return strings.TrimRight(line.String(), " ")
+}
+
+// String returns the text on every row of the screen, separated by new lines
+func (s *Screen) String() string {
+	s.terminal.Lock()
+	defer s.terminal.Unlock()
+	lines := make([]string, s.terminal.GetActiveBuffer().ViewHeight())
+	for y := range lines {
+		lines[y] = s.line(uint16(y))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Contains returns true if the text appears on a single row of the screen
+func (s *Screen) Contains(text string) bool {
+	s.terminal.Lock()
+	defer s.terminal.Unlock()
+	rows := s.terminal.GetActiveBuffer().ViewHeight()
+	for y := uint16(0); y < rows; y++ {
+		if strings.Contains(s.line(y), text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Cursor returns the position of the cursor
+func (s *Screen) Cursor() (x uint16, y uint16) {
+	s.terminal.Lock()
+	defer s.terminal.Unlock()
+	buffer := s.terminal.GetActiveBuffer()
+	return buffer.CursorColumn(), buffer.CursorLine()
+}
+
+// CursorStyle returns the cursor style which has been set with DECSCUSR, or 0 for the default
+func (s *Screen) CursorStyle() int {
+	return s.terminal.CursorStyle()
+}
+
+// Hyperlink returns the URI of the hyperlink (OSC 8) the cell at a position is part of, or an empty string
+func (s *Screen) Hyperlink(x, y uint16) string {
+	s.terminal.Lock()
+	defer s.terminal.Unlock()
+	cell := s.terminal.GetActiveBuffer().GetCell(x, y)
+	if cell == nil || cell.Attr().Hyperlink() == nil {
+		return ""
+	}
+	return cell.Attr().Hyperlink().URI
+}
+
+// Close stops processing output
+func (s *Screen) Close() {
+	s.terminal.Close()
+}
@@ -0,0 +1,175 @@
+// Package sundertest runs the multiplexer against a virtual screen, so tests can type into it and check what it
+// draws.
+package sundertest
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/liamg/sunder/pkg/multiplexer"
+	"github.com/liamg/sunder/pkg/screen"
+)
+
+// Timeout is how long to wait for the screen to show what a test expects
+var Timeout = 5 * time.Second
+
+// Harness runs a multiplexer with keyboard input and screen output controlled by a test
+type Harness struct {
+	t           testing.TB
+	multiplexer *multiplexer.Multiplexer
+	screen      *screen.Screen
+	input       *io.PipeWriter
+	done        chan error
+	err         error
+	stopped     bool
+}
+
+// Shell creates a plain /bin/sh with a predictable "$ " prompt, for use with multiplexer.WithShell
+func Shell() *exec.Cmd {
+	return Command("/bin/sh")
+}
+
+// Command creates a command which runs in a predictable environment, for use with multiplexer.WithShell
+func Command(name string, args ...string) *exec.Cmd {
+	cmd := exec.Command(name, args...)
+	cmd.Env = append(os.Environ(), "PS1=$ ", "ENV=", "TERM=xterm-256color")
+	return cmd
+}
+
+// New starts a multiplexer drawing to a virtual screen of the given size. Panes run Shell unless another shell is
+// given in the options. The multiplexer is closed when the test finishes.
+func New(t testing.TB, rows uint16, cols uint16, options ...multiplexer.Option) *Harness {
+	t.Helper()
+
+	inputReader, inputWriter := io.Pipe()
+	h := &Harness{
+		t:      t,
+		screen: screen.New(rows, cols),
+		input:  inputWriter,
+		done:   make(chan error, 1),
+	}
+
+	options = append([]multiplexer.Option{multiplexer.WithShell(Shell)}, options...)
+	options = append(options,
+		multiplexer.WithInput(inputReader),
+		multiplexer.WithOutput(h.screen),
+		multiplexer.WithSize(rows, cols),
+	)
+	h.multiplexer = multiplexer.New(options...)
+
+	go func() {
+		h.done <- h.multiplexer.Start()
+	}()
+
+	t.Cleanup(h.Close)
+	return h
+}
+
+// Multiplexer returns the multiplexer under test
+func (h *Harness) Multiplexer() *multiplexer.Multiplexer {
+	return h.multiplexer
+}
+
+// Screen returns the virtual screen the multiplexer draws to
+func (h *Harness) Screen() *screen.Screen {
+	return h.screen
+}
+
+// Type sends keystrokes to the multiplexer, e.g. "\x01v" for ctrl-a v
+func (h *Harness) Type(keys string) {
+	h.t.Helper()
+	if _, err := h.input.Write([]byte(keys)); err != nil {
+		h.t.Fatalf("failed to type %q: %s", keys, err)
+	}
+}
+
+// Run types a command into the active pane and presses enter
+func (h *Harness) Run(command string) {
+	h.t.Helper()
+	h.Type(command + "\r")
+}
+
+// WaitFor waits for text to appear on a single row of the screen, failing the test if it doesn't appear in time
+func (h *Harness) WaitFor(text string) {
+	h.t.Helper()
+	h.WaitUntil("screen to contain "+text, func(s *screen.Screen) bool {
+		return s.Contains(text)
+	})
+}
+
+// WaitForGone waits for text to disappear from the screen, failing the test if it is still there in time
+func (h *Harness) WaitForGone(text string) {
+	h.t.Helper()
+	h.WaitUntil("screen not to contain "+text, func(s *screen.Screen) bool {
+		return !s.Contains(text)
+	})
+}
+
+// WaitUntil waits for a condition on the screen to be met, failing the test with the contents of the screen if
+// it isn't met in time
+func (h *Harness) WaitUntil(description string, condition func(s *screen.Screen) bool) {
+	h.t.Helper()
+	deadline := time.Now().Add(Timeout)
+	for {
+		if condition(h.screen) {
+			return
+		}
+		if time.Now().After(deadline) {
+			h.t.Fatalf("timed out waiting for %s, screen was:\n%s", description, frame(h.screen.String()))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Resize changes the size of the virtual screen and the multiplexer
+func (h *Harness) Resize(rows uint16, cols uint16) {
+	h.t.Helper()
+	h.screen.Resize(rows, cols)
+	if err := h.multiplexer.Resize(rows, cols); err != nil {
+		h.t.Fatalf("failed to resize: %s", err)
+	}
+}
+
+// Wait waits for the multiplexer to exit by itself, e.g. after the shell in the last pane has exited, and returns
+// the error returned by Start
+func (h *Harness) Wait() error {
+	h.t.Helper()
+	if h.stopped {
+		return h.err
+	}
+	select {
+	case h.err = <-h.done:
+		h.stopped = true
+	case <-time.After(Timeout):
+		h.t.Fatalf("timed out waiting for the multiplexer to exit, screen was:\n%s", frame(h.screen.String()))
+	}
+	return h.err
+}
+
+// Close stops the multiplexer and the programs running in it
+func (h *Harness) Close() {
+	_ = h.input.Close()
+	h.multiplexer.Close()
+	if !h.stopped {
+		select {
+		case h.err = <-h.done:
+		case <-time.After(Timeout):
+			h.t.Errorf("timed out waiting for the multiplexer to stop")
+		}
+		h.stopped = true
+	}
+	h.screen.Close()
+}
+
+// frame draws a border around the screen contents, so trailing space and empty lines are visible in test output
+func frame(text string) string {
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = "|" + line
+	}
+	return strings.Join(lines, "\n")
+}
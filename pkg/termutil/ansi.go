@@ -2,7 +2,7 @@ package termutil
 
 func (t *Terminal) handleANSI(readChan chan MeasuredRune) (renderRequired bool) {
 	// if the byte is an escape character, read the next byte to determine which one
-	r := <-readChan
+	r := t.readRune(readChan)
 
 	switch r.Rune {
 	case '[':
@@ -14,13 +14,13 @@ func (t *Terminal) handleANSI(readChan chan MeasuredRune) (renderRequired bool)
 	case ')':
 		return t.handleSCS1(readChan) // select character set into G1
 	case '*':
-		return swallowHandler(1)(readChan) // character set bullshit
+		return t.swallowHandler(1)(readChan) // character set bullshit
 	case '+':
-		return swallowHandler(1)(readChan) // character set bullshit
+		return t.swallowHandler(1)(readChan) // character set bullshit
 	case '>':
-		return swallowHandler(0)(readChan) // numeric char selection  //@todo
+		t.GetActiveBuffer().modes.ApplicationKeypad = false // DECKPNM
 	case '=':
-		return swallowHandler(0)(readChan) // alt char selection  //@todo
+		t.GetActiveBuffer().modes.ApplicationKeypad = true // DECKPAM
 	case '7':
 		t.GetActiveBuffer().saveCursor()
 	case '8':
@@ -37,6 +37,10 @@ func (t *Terminal) handleANSI(readChan chan MeasuredRune) (renderRequired bool)
 		return false
 	case 'c':
 		t.GetActiveBuffer().clear()
+		t.GetActiveBuffer().modes.ApplicationCursorKeys = false
+		t.GetActiveBuffer().modes.ApplicationKeypad = false
+		t.resetKeyboard()
+		t.cursorStyle = 0
 	case '#':
 		return t.handleScreenState(readChan)
 	case '^':
@@ -50,17 +54,17 @@ func (t *Terminal) handleANSI(readChan chan MeasuredRune) (renderRequired bool)
 	return true
 }
 
-func swallowHandler(size int) func(pty chan MeasuredRune) bool {
+func (t *Terminal) swallowHandler(size int) func(pty chan MeasuredRune) bool {
 	return func(pty chan MeasuredRune) bool {
 		for i := 0; i < size; i++ {
-			<-pty
+			t.readRune(pty)
 		}
 		return false
 	}
 }
 
 func (t *Terminal) handleScreenState(readChan chan MeasuredRune) bool {
-	b := <-readChan
+	b := t.readRune(readChan)
 	switch b.Rune {
 	case '8': // DECALN -- Screen Alignment Pattern
 
@@ -90,7 +94,7 @@ func (t *Terminal) handleScreenState(readChan chan MeasuredRune) bool {
 func (t *Terminal) handlePrivacyMessage(readChan chan MeasuredRune) bool {
 	isEscaped := false
 	for {
-		b := <-readChan
+		b := t.readRune(readChan)
 		if b.Rune == 0x18 /*CAN*/ || b.Rune == 0x1a /*SUB*/ || (b.Rune == 0x5c /*backslash*/ && isEscaped) {
 			break
 		}
@@ -73,6 +73,10 @@ func (buffer *Buffer) IsCursorVisible() bool {
 	return buffer.modes.ShowCursor
 }
 
+func (buffer *Buffer) IsBracketedPasteMode() bool {
+	return buffer.bracketedPasteMode
+}
+
 func (buffer *Buffer) HasScrollableRegion() bool {
 	return buffer.topMargin > 0 || buffer.bottomMargin < uint(buffer.ViewHeight())-1
 }
@@ -374,6 +378,11 @@ func (buffer *Buffer) write(runes ...MeasuredRune) {
 
 	for _, r := range runes {
 
+		if r.Width == 0 {
+			buffer.writeCombining(r.Rune)
+			continue
+		}
+
 		line := buffer.getCurrentLine()
 
 		if buffer.modes.ReplaceMode {
@@ -383,15 +392,22 @@ func (buffer *Buffer) write(runes ...MeasuredRune) {
 				return
 			}
 
-			for int(buffer.CursorColumn()) >= len(line.cells) {
-				line.append(buffer.defaultCell(int(buffer.CursorColumn()) == len(line.cells)))
-			}
-			line.cells[buffer.cursorX].attr = buffer.cursorAttr
-			line.cells[buffer.cursorX].setRune(r)
+			buffer.putRune(line, int(buffer.CursorColumn()), r)
 			buffer.incrementCursorPosition()
+			if r.Width > 1 {
+				buffer.incrementCursorPosition()
+			}
 			continue
 		}
 
+		// like xterm, a wide character which doesn't fit at the end of the line is moved onto the next one
+		if r.Width > 1 && buffer.CursorColumn()+1 == buffer.Width() {
+			if !buffer.modes.AutoWrap {
+				return
+			}
+			buffer.cursorX = buffer.Width()
+		}
+
 		if buffer.CursorColumn() >= buffer.Width() { // if we're after the line, move to next
 
 			if buffer.modes.AutoWrap {
@@ -400,12 +416,7 @@ func (buffer *Buffer) write(runes ...MeasuredRune) {
 
 				newLine := buffer.getCurrentLine()
 				newLine.setNoBreak(true)
-				if len(newLine.cells) == 0 {
-					newLine.append(buffer.defaultCell(true))
-				}
-				cell := &newLine.cells[0]
-				cell.setRune(r)
-				cell.attr = buffer.cursorAttr
+				buffer.putRune(newLine, 0, r)
 
 			} else {
 				// no more room on line and wrapping is disabled
@@ -414,20 +425,56 @@ func (buffer *Buffer) write(runes ...MeasuredRune) {
 
 			// @todo if next line is wrapped then prepend to it and shuffle characters along line, wrapping to next if necessary
 		} else {
-
-			for int(buffer.CursorColumn()) >= len(line.cells) {
-				line.append(buffer.defaultCell(int(buffer.CursorColumn()) == len(line.cells)))
-			}
-
-			cell := &line.cells[buffer.CursorColumn()]
-			cell.setRune(r)
-			cell.attr = buffer.cursorAttr
+			buffer.putRune(line, int(buffer.CursorColumn()), r)
 		}
 
 		buffer.incrementCursorPosition()
+		if r.Width > 1 {
+			buffer.incrementCursorPosition()
+		}
 	}
 }
 
+// putRune writes a character into the cell at x, with the right half of a wide character going into the next cell
+func (buffer *Buffer) putRune(line *Line, x int, r MeasuredRune) {
+	width := 1
+	if r.Width > 1 {
+		width = 2
+	}
+	for len(line.cells) < x+width {
+		line.append(buffer.defaultCell(len(line.cells) >= x))
+	}
+
+	// overwriting half of a wide character leaves the other half blank
+	line.clearWide(x)
+	if width > 1 {
+		line.clearWide(x + 1)
+	}
+
+	cell := &line.cells[x]
+	cell.setRune(r)
+	cell.attr = buffer.cursorAttr
+	if width > 1 {
+		continuation := &line.cells[x+1]
+		continuation.setRune(MeasuredRune{})
+		continuation.continuation = true
+		continuation.attr = buffer.cursorAttr
+	}
+}
+
+// writeCombining adds a combining mark to the character before the cursor
+func (buffer *Buffer) writeCombining(r rune) {
+	line := buffer.getCurrentLine()
+	x := int(buffer.CursorColumn()) - 1
+	if x < 0 || x >= len(line.cells) {
+		return
+	}
+	if line.cells[x].continuation && x > 0 {
+		x--
+	}
+	line.cells[x].addCombining(r)
+}
+
 func (buffer *Buffer) incrementCursorPosition() {
 	// we can increment one column past the end of the line.
 	// this is effectively the beginning of the next line, except when we \r etc.
@@ -597,6 +644,25 @@ func (buffer *Buffer) GetVisibleLines() []Line {
 	return lines
 }
 
+// GetLines returns the visible lines of the buffer, preceded by up to scrollback lines of history
+func (buffer *Buffer) GetLines(scrollback int) []Line {
+	start := len(buffer.lines) - int(buffer.viewHeight) - scrollback
+	if start < 0 {
+		start = 0
+	}
+	lines := make([]Line, len(buffer.lines)-start)
+	copy(lines, buffer.lines[start:])
+	return lines
+}
+
+// Line returns the line at the given index, counting from the oldest line of history
+func (buffer *Buffer) Line(index int) *Line {
+	if index < 0 || index >= len(buffer.lines) {
+		return nil
+	}
+	return &buffer.lines[index]
+}
+
 // tested to here
 
 func (buffer *Buffer) clear() {
@@ -895,6 +961,8 @@ func (buffer *Buffer) resetVerticalMargins(height uint) {
 
 func (buffer *Buffer) defaultCell(applyEffects bool) Cell {
 	attr := buffer.cursorAttr
+	// blank cells are never part of a link
+	attr.hyperlink = nil
 	if !applyEffects {
 		attr.blink = false
 		attr.bold = false
@@ -0,0 +1,74 @@
+package termutil
+
+type Cell struct {
+	r    MeasuredRune
+	attr CellAttributes
+	// combining marks drawn on top of the character
+	combining []rune
+	// the cell is the right half of the wide character in the cell to its left
+	continuation bool
+}
+
+func (cell *Cell) Attr() CellAttributes {
+	return cell.attr
+}
+
+func (cell *Cell) Rune() MeasuredRune {
+	return cell.r
+}
+
+// Combining returns the combining marks drawn on top of the character in the cell
+func (cell *Cell) Combining() []rune {
+	return cell.combining
+}
+
+// IsContinuation returns true if the cell is the right half of the wide character in the cell to its left
+func (cell *Cell) IsContinuation() bool {
+	return cell.continuation
+}
+
+// String returns the text shown in the cell: its character followed by any combining marks. Empty cells are a
+// space, and the right half of a wide character is empty, as the character is shown in the cell to its left.
+func (cell *Cell) String() string {
+	switch {
+	case cell.continuation:
+		return ""
+	case cell.r.Rune < 0x20:
+		return " "
+	case len(cell.combining) == 0:
+		return string(cell.r.Rune)
+	}
+	return string(append([]rune{cell.r.Rune}, cell.combining...))
+}
+
+func (cell *Cell) Fg() Colour {
+	if cell.Attr().inverse {
+		return cell.attr.bgColour
+	}
+	return cell.attr.fgColour
+}
+
+func (cell *Cell) Bg() Colour {
+	if cell.Attr().inverse {
+		return cell.attr.fgColour
+	}
+	return cell.attr.bgColour
+}
+
+func (cell *Cell) erase(bgColour Colour) {
+	cell.setRune(MeasuredRune{Rune: 0})
+	cell.attr.bgColour = bgColour
+	cell.attr.hyperlink = nil
+}
+
+func (cell *Cell) setRune(r MeasuredRune) {
+	cell.r = r
+	cell.combining = nil
+	cell.continuation = false
+}
+
+// addCombining adds a combining mark to the character in the cell
+func (cell *Cell) addCombining(r rune) {
+	// copy rather than append in place, as cells are copied by value and may share the slice
+	cell.combining = append(cell.combining[:len(cell.combining):len(cell.combining)], r)
+}
@@ -0,0 +1,178 @@
+package termutil
+
+import (
+	"strings"
+
+	"github.com/liamg/sunder/pkg/capability"
+)
+
+type CellAttributes struct {
+	fgColour  Colour
+	bgColour  Colour
+	bold      bool
+	dim       bool
+	underline bool
+	blink     bool
+	inverse   bool
+	hidden    bool
+	// the hyperlink the cell is part of, if any (OSC 8)
+	hyperlink *Hyperlink
+}
+
+// Hyperlink is a link set by the program with OSC 8. Cells which share an ID and URI are part of the same link, even
+// if they aren't next to each other.
+type Hyperlink struct {
+	ID  string
+	URI string
+}
+
+func (cellAttr *CellAttributes) reverseVideo() {
+	oldFgColour := cellAttr.fgColour
+	cellAttr.fgColour = cellAttr.bgColour
+	cellAttr.bgColour = oldFgColour
+}
+
+// FgColour returns the foreground colour, ignoring inverse video
+func (cellAttr CellAttributes) FgColour() Colour {
+	return cellAttr.fgColour
+}
+
+// BgColour returns the background colour, ignoring inverse video
+func (cellAttr CellAttributes) BgColour() Colour {
+	return cellAttr.bgColour
+}
+
+func (cellAttr CellAttributes) Bold() bool {
+	return cellAttr.bold
+}
+
+func (cellAttr CellAttributes) Dim() bool {
+	return cellAttr.dim
+}
+
+func (cellAttr CellAttributes) Underline() bool {
+	return cellAttr.underline
+}
+
+func (cellAttr CellAttributes) Blink() bool {
+	return cellAttr.blink
+}
+
+func (cellAttr CellAttributes) Inverse() bool {
+	return cellAttr.inverse
+}
+
+func (cellAttr CellAttributes) Hidden() bool {
+	return cellAttr.hidden
+}
+
+// Hyperlink returns the hyperlink the cell is part of, or nil if it isn't part of one
+func (cellAttr CellAttributes) Hyperlink() *Hyperlink {
+	return cellAttr.hyperlink
+}
+
+// WithHyperlink returns a copy of the attributes with the given hyperlink, or without a hyperlink if it is nil
+func (cellAttr CellAttributes) WithHyperlink(link *Hyperlink) CellAttributes {
+	cellAttr.hyperlink = link
+	return cellAttr
+}
+
+// WithColours returns a copy of the attributes using the given colours, with inverse video removed
+func (cellAttr CellAttributes) WithColours(fg Colour, bg Colour) CellAttributes {
+	cellAttr.fgColour = fg
+	cellAttr.bgColour = bg
+	cellAttr.inverse = false
+	cellAttr.hidden = false
+	return cellAttr
+}
+
+// Downsample returns a copy of the attributes which only uses the colours and attributes a terminal can display
+func (cellAttr CellAttributes) Downsample(caps capability.Capabilities) CellAttributes {
+	cellAttr.fgColour = cellAttr.fgColour.Downsample(caps.Colours)
+	cellAttr.bgColour = cellAttr.bgColour.Downsample(caps.Colours)
+	cellAttr.bold = cellAttr.bold && caps.Supports(capability.Bold)
+	cellAttr.dim = cellAttr.dim && caps.Supports(capability.Dim)
+	cellAttr.underline = cellAttr.underline && caps.Supports(capability.Underline)
+	cellAttr.blink = cellAttr.blink && caps.Supports(capability.Blink)
+	cellAttr.inverse = cellAttr.inverse && caps.Supports(capability.Inverse)
+	cellAttr.hidden = cellAttr.hidden && caps.Supports(capability.Hidden)
+	return cellAttr
+}
+
+// GetDiffANSI takes a previous cell attribute set and diffs to this one, producing the
+// most efficient ANSI output to achieve the diff
+func (cellAttr CellAttributes) GetDiffANSI(prev CellAttributes) string {
+
+	var segments []string
+
+	// not every terminal supports switching off attributes individually, so reset if any have been removed
+	if (prev.bold && !cellAttr.bold) ||
+		(prev.dim && !cellAttr.dim) ||
+		(prev.underline && !cellAttr.underline) ||
+		(prev.blink && !cellAttr.blink) ||
+		(prev.inverse && !cellAttr.inverse) ||
+		(prev.hidden && !cellAttr.hidden) {
+		segments = append(segments, "0")
+		prev = CellAttributes{}
+	}
+
+	if cellAttr.bold && !prev.bold {
+		segments = append(segments, "1")
+	}
+	if cellAttr.dim && !prev.dim {
+		segments = append(segments, "2")
+	}
+	if cellAttr.underline && !prev.underline {
+		segments = append(segments, "4")
+	}
+	if cellAttr.blink && !prev.blink {
+		segments = append(segments, "5")
+	}
+	if cellAttr.inverse && !prev.inverse {
+		segments = append(segments, "7")
+	}
+	if cellAttr.hidden && !prev.hidden {
+		segments = append(segments, "8")
+	}
+
+	// set fg
+	if prev.fgColour != cellAttr.fgColour {
+		if cellAttr.fgColour == "" {
+			segments = append(segments, "39")
+		} else {
+			segments = append(segments, string(cellAttr.fgColour))
+		}
+	}
+
+	// set bg
+	if prev.bgColour != cellAttr.bgColour {
+		if cellAttr.bgColour == "" {
+			segments = append(segments, "49")
+		} else {
+			segments = append(segments, string(cellAttr.bgColour))
+		}
+	}
+
+	if len(segments) == 0 {
+		return ""
+	}
+
+	return "\x1b[" + strings.Join(segments, ";") + "m"
+}
+
+// GetHyperlinkDiffANSI takes a previous cell attribute set and produces the OSC 8 sequence required to start or end
+// the hyperlink of this one, if it is different
+func (cellAttr CellAttributes) GetHyperlinkDiffANSI(prev CellAttributes) string {
+	link := cellAttr.hyperlink
+	if link == prev.hyperlink || (link != nil && prev.hyperlink != nil && *link == *prev.hyperlink) {
+		return ""
+	}
+	if link == nil {
+		return "\x1b]8;;\x1b\\"
+	}
+	var params string
+	if link.ID != "" {
+		params = "id=" + link.ID
+	}
+	return "\x1b]8;" + params + ";" + link.URI + "\x1b\\"
+}
@@ -50,7 +50,7 @@ func (t *Terminal) handleSCS1(pty chan MeasuredRune) bool {
 }
 
 func (t *Terminal) scsHandler(pty chan MeasuredRune, which int) bool {
-	b := <-pty
+	b := t.readRune(pty)
 
 	cs, ok := charSets[b.Rune]
 	if ok {
@@ -0,0 +1,135 @@
+package termutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/liamg/sunder/pkg/capability"
+)
+
+// Colour is the SGR parameter string used to select a colour, e.g. "31", "38;5;208" or "48;2;255;0;0"
+type Colour string
+
+// standard xterm values for the 16 basic colours
+var basicColours = [16][3]uint8{
+	{0, 0, 0}, {205, 0, 0}, {0, 205, 0}, {205, 205, 0},
+	{0, 0, 238}, {205, 0, 205}, {0, 205, 205}, {229, 229, 229},
+	{127, 127, 127}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{92, 92, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// RGB resolves the colour to its red, green and blue components using the xterm palette.
+// ok is false for the default colour, which is decided by the terminal.
+func (c Colour) RGB() (r, g, b uint8, ok bool) {
+	parts := strings.Split(string(c), ";")
+	code, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	switch {
+	case code >= 30 && code <= 37:
+		return paletteRGB(code - 30)
+	case code >= 40 && code <= 47:
+		return paletteRGB(code - 40)
+	case code >= 90 && code <= 97:
+		return paletteRGB(code - 90 + 8)
+	case code >= 100 && code <= 107:
+		return paletteRGB(code - 100 + 8)
+	case (code == 38 || code == 48) && len(parts) == 3 && parts[1] == "5":
+		index, err := strconv.Atoi(parts[2])
+		if err != nil {
+			return 0, 0, 0, false
+		}
+		return paletteRGB(index)
+	case (code == 38 || code == 48) && len(parts) == 5 && parts[1] == "2":
+		var rgb [3]uint8
+		for i := range rgb {
+			v, err := strconv.Atoi(parts[2+i])
+			if err != nil || v < 0 || v > 255 {
+				return 0, 0, 0, false
+			}
+			rgb[i] = uint8(v)
+		}
+		return rgb[0], rgb[1], rgb[2], true
+	}
+	return 0, 0, 0, false
+}
+
+// paletteRGB returns the RGB value of the given entry in the xterm 256 colour palette
+func paletteRGB(index int) (r, g, b uint8, ok bool) {
+	switch {
+	case index < 0 || index > 255:
+		return 0, 0, 0, false
+	case index < 16:
+		c := basicColours[index]
+		return c[0], c[1], c[2], true
+	case index < 232:
+		// 6x6x6 colour cube
+		index -= 16
+		levels := [6]uint8{0, 95, 135, 175, 215, 255}
+		return levels[index/36], levels[(index/6)%6], levels[index%6], true
+	default:
+		// greyscale ramp
+		grey := uint8(8 + (index-232)*10)
+		return grey, grey, grey, true
+	}
+}
+
+// Downsample returns the closest colour the given colour depth can display. Colours are removed entirely on
+// monochrome terminals.
+func (c Colour) Downsample(depth capability.ColourDepth) Colour {
+	if c == "" || depth >= capability.TrueColour {
+		return c
+	}
+	if depth == capability.Monochrome {
+		return ""
+	}
+	parts := strings.Split(string(c), ";")
+	code, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return c
+	}
+	background := (code >= 40 && code <= 48) || (code >= 100 && code <= 107)
+
+	r, g, b, ok := c.RGB()
+	if !ok {
+		return c
+	}
+
+	switch depth {
+	case capability.Colours256:
+		if len(parts) < 2 || parts[1] != "2" {
+			// already in the palette
+			return c
+		}
+		index := nearestPaletteColour(r, g, b, 16, 256)
+		if background {
+			return Colour(fmt.Sprintf("48;5;%d", index))
+		}
+		return Colour(fmt.Sprintf("38;5;%d", index))
+	default:
+		index := nearestPaletteColour(r, g, b, 0, int(depth))
+		base := 30
+		if index >= 8 {
+			base = 90 - 8
+		}
+		if background {
+			base += 10
+		}
+		return Colour(strconv.Itoa(base + index))
+	}
+}
+
+// nearestPaletteColour returns the index in the xterm palette between from and to which is closest to the colour
+func nearestPaletteColour(r, g, b uint8, from, to int) int {
+	best, bestDistance := from, -1
+	for index := from; index < to; index++ {
+		pr, pg, pb, _ := paletteRGB(index)
+		dr, dg, db := int(r)-int(pr), int(g)-int(pg), int(b)-int(pb)
+		if distance := dr*dr + dg*dg + db*db; bestDistance < 0 || distance < bestDistance {
+			best, bestDistance = index, distance
+		}
+	}
+	return best
+}
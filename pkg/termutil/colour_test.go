@@ -0,0 +1,49 @@
+package termutil
+
+import (
+	"testing"
+
+	"github.com/liamg/sunder/pkg/capability"
+)
+
+func TestColourDownsample(t *testing.T) {
+	tests := []struct {
+		name     string
+		colour   Colour
+		depth    capability.ColourDepth
+		expected Colour
+	}{
+		{"truecolour is kept", "38;2;255;135;0", capability.TrueColour, "38;2;255;135;0"},
+		{"default is kept", "", capability.Colours8, ""},
+		{"monochrome removes colour", "31", capability.Monochrome, ""},
+		{"monochrome removes truecolour", "48;2;255;0;0", capability.Monochrome, ""},
+
+		{"truecolour foreground to 256", "38;2;255;0;0", capability.Colours256, "38;5;196"},
+		{"truecolour background to 256", "48;2;255;0;0", capability.Colours256, "48;5;196"},
+		{"truecolour grey to 256", "38;2;128;128;128", capability.Colours256, "38;5;244"},
+		{"256 colour is kept at 256", "38;5;208", capability.Colours256, "38;5;208"},
+		{"basic colour is kept at 256", "42", capability.Colours256, "42"},
+
+		{"truecolour foreground to 16", "38;2;255;0;0", capability.Colours16, "91"},
+		{"truecolour background to 16", "48;2;255;0;0", capability.Colours16, "101"},
+		{"dark truecolour background to 16", "48;2;0;200;0", capability.Colours16, "42"},
+		{"256 colour foreground to 16", "38;5;208", capability.Colours16, "33"},
+		{"256 colour background to 16", "48;5;255", capability.Colours16, "47"},
+		{"bright colour is kept at 16", "95", capability.Colours16, "95"},
+
+		{"truecolour foreground to 8", "38;2;255;0;0", capability.Colours8, "31"},
+		{"truecolour background to 8", "48;2;255;0;0", capability.Colours8, "41"},
+		{"truecolour light grey to 8", "38;2;200;200;200", capability.Colours8, "37"},
+		{"bright foreground to 8", "91", capability.Colours8, "31"},
+		{"bright background to 8", "104", capability.Colours8, "44"},
+		{"256 colour background to 8", "48;5;232", capability.Colours8, "40"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			if actual := test.colour.Downsample(test.depth); actual != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
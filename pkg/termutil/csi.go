@@ -6,14 +6,14 @@ import (
 	"strings"
 )
 
-func parseCSI(readChan chan MeasuredRune) (final rune, params []string, intermediate []rune, raw []rune) {
+func (t *Terminal) parseCSI(readChan chan MeasuredRune) (final rune, params []string, intermediate []rune, raw []rune) {
 	var b MeasuredRune
 
 	param := ""
 	intermediate = []rune{}
 CSI:
 	for {
-		b = <-readChan
+		b = t.readRune(readChan)
 		raw = append(raw, b.Rune)
 		switch true {
 		case b.Rune >= 0x30 && b.Rune <= 0x3F:
@@ -41,15 +41,15 @@ CSI:
 }
 
 func (t *Terminal) handleCSI(readChan chan MeasuredRune) (renderRequired bool) {
-	final, params, intermediate, raw := parseCSI(readChan)
+	final, params, intermediate, raw := t.parseCSI(readChan)
 
 	t.log("CSI P(%q) I(%q) %c", strings.Join(params, ";"), string(intermediate), final)
 
+	// control characters inside a sequence are executed as usual, the rest are intermediate bytes
 	for _, b := range intermediate {
-		t.processRunes(MeasuredRune{
-			Rune:  b,
-			Width: 1, // TODO: measure these? should only be control characters...
-		})
+		if b < 0x20 {
+			t.processRunes(MeasuredRune{Rune: b, Width: 1})
+		}
 	}
 
 	switch final {
@@ -66,13 +66,31 @@ func (t *Terminal) handleCSI(readChan chan MeasuredRune) (renderRequired bool) {
 	case 'l':
 		return t.csiResetModeHandler(params)
 	case 'm':
+		if len(params) > 0 && strings.HasPrefix(params[0], ">") {
+			return t.csiKeyModifierOptionsHandler(params)
+		}
 		return t.sgrSequenceHandler(params)
 	case 'n':
 		return t.csiDeviceStatusReportHandler(params)
+	case 'p':
+		if string(intermediate) == "$" {
+			return t.csiRequestModeHandler(params)
+		}
+		return false
+	case 'q':
+		if string(intermediate) == " " {
+			return t.csiSetCursorStyleHandler(params)
+		}
+		if len(params) > 0 && strings.HasPrefix(params[0], ">") {
+			return t.csiReportVersionHandler()
+		}
+		return false
 	case 'r':
 		return t.csiSetMarginsHandler(params)
-	//case 't':
-	// TODO return t.csiWindowManipulation(params)
+	case 'u':
+		return t.csiKeyboardFlagsHandler(params)
+	case 't':
+		return t.csiWindowManipulationHandler(params)
 	case 'A':
 		return t.csiCursorUpHandler(params)
 	case 'B':
@@ -122,12 +140,15 @@ func (t *Terminal) handleCSI(readChan chan MeasuredRune) (renderRequired bool) {
 // Send Device Attributes (Primary/Secondary/Tertiary DA)
 func (t *Terminal) csiSendDeviceAttributesHandler(params []string) (renderRequired bool) {
 
-	// we are VT100
-	// for DA1 we'll respond ?1;2
-	// for DA2 we'll respond >0;0;0
-	response := "?1;2"
-	if len(params) > 0 && len(params[0]) > 0 && params[0][0] == '>' {
-		response = ">0;0;0"
+	// for DA1 we are a VT220 with ANSI colour, and for DA2 a VT220 with no firmware version. DA3 isn't answered.
+	response := "?62;22"
+	if len(params) > 0 && len(params[0]) > 0 {
+		switch params[0][0] {
+		case '>':
+			response = ">1;0;0"
+		case '=':
+			return false
+		}
 	}
 
 	// write response to source pty
@@ -135,23 +156,46 @@ func (t *Terminal) csiSendDeviceAttributesHandler(params []string) (renderRequir
 	return false
 }
 
+// CSI > q
+// Report the name and version of the terminal (XTVERSION)
+func (t *Terminal) csiReportVersionHandler() (renderRequired bool) {
+	t.respondToPty([]byte("\x1bP>|" + t.version + "\x1b\\"))
+	return false
+}
+
+// CSI 18 t
+// Report the size of the text area in characters. Other window operations aren't supported.
+func (t *Terminal) csiWindowManipulationHandler(params []string) (renderRequired bool) {
+	if len(params) > 0 && params[0] == "18" {
+		buffer := t.GetActiveBuffer()
+		t.respondToPty([]byte(fmt.Sprintf("\x1b[8;%d;%dt", buffer.ViewHeight(), buffer.ViewWidth())))
+	}
+	return false
+}
+
 // CSI n
-// Device Status Report (DSR)
+// Device Status Report (DSR). The cursor position is relative to the screen of the terminal, or to the top margin
+// in origin mode.
 func (t *Terminal) csiDeviceStatusReportHandler(params []string) (renderRequired bool) {
 
 	if len(params) == 0 {
 		return false
 	}
 
+	buffer := t.GetActiveBuffer()
+	// after writing in the last column the cursor waits past the end of the line, but is reported in the last column
+	column := buffer.CursorColumn()
+	if column >= buffer.ViewWidth() && column > 0 {
+		column = buffer.ViewWidth() - 1
+	}
+
 	switch params[0] {
 	case "5":
 		t.respondToPty([]byte("\x1b[0n")) // everything is cool
 	case "6": // report cursor position
-		t.respondToPty([]byte(fmt.Sprintf(
-			"\x1b[%d;%dR",
-			t.GetActiveBuffer().CursorLine()+1,
-			t.GetActiveBuffer().CursorColumn()+1,
-		)))
+		t.respondToPty([]byte(fmt.Sprintf("\x1b[%d;%dR", buffer.CursorLine()+1, column+1)))
+	case "?6": // report cursor position and page (DECXCPR)
+		t.respondToPty([]byte(fmt.Sprintf("\x1b[?%d;%d;1R", buffer.CursorLine()+1, column+1)))
 	}
 
 	return false
@@ -621,8 +665,12 @@ func (t *Terminal) csiSetMode(modeStr string, enabled bool) bool {
 		} else {
 			t.useMainBuffer()
 		}
+	case "?1004":
+		t.focusReporting = enabled
 	case "?2004":
 		t.activeBuffer.bracketedPasteMode = enabled
+	case "?2026":
+		t.setSynchronizedUpdate(enabled)
 	default:
 		//return fmt.Errorf("Unsupported CSI %s%s code", modeStr, recoverCodeFromEnabled(enabled))
 	}
@@ -630,6 +678,55 @@ func (t *Terminal) csiSetMode(modeStr string, enabled bool) bool {
 	return false
 }
 
+// CSI ? Ps $ p
+// Request DEC private mode (DECRQM). Modes we don't track are reported as not recognised (0), otherwise as set (1)
+// or reset (2).
+func (t *Terminal) csiRequestModeHandler(params []string) (renderRequired bool) {
+	if len(params) == 0 || !strings.HasPrefix(params[0], "?") {
+		return false
+	}
+	mode := params[0]
+	var status int
+	switch mode {
+	case "?1":
+		status = modeStatus(t.activeBuffer.modes.ApplicationCursorKeys)
+	case "?7":
+		status = modeStatus(t.activeBuffer.modes.AutoWrap)
+	case "?25":
+		status = modeStatus(t.activeBuffer.modes.ShowCursor)
+	case "?1004":
+		status = modeStatus(t.focusReporting)
+	case "?2004":
+		status = modeStatus(t.activeBuffer.bracketedPasteMode)
+	case "?2026":
+		status = modeStatus(t.InSynchronizedUpdate())
+	}
+	t.respondToPty([]byte(fmt.Sprintf("\x1b[%s;%d$y", mode, status)))
+	return false
+}
+
+// CSI Ps SP q
+// Set cursor style (DECSCUSR). 0 and 1 are a blinking block, 2 a steady block, 3 and 4 a blinking and steady
+// underline, and 5 and 6 a blinking and steady bar.
+func (t *Terminal) csiSetCursorStyleHandler(params []string) (renderRequired bool) {
+	style := 0
+	if len(params) > 0 {
+		var err error
+		if style, err = strconv.Atoi(params[0]); err != nil || style < 0 || style > 6 {
+			return false
+		}
+	}
+	t.cursorStyle = style
+	return true
+}
+
+func modeStatus(set bool) int {
+	if set {
+		return 1
+	}
+	return 2
+}
+
 // CSI d
 // Line Position Absolute  [row] (default = [1,column]) (VPA)
 func (t *Terminal) csiLinePositionAbsoluteHandler(params []string) (renderRequired bool) {
@@ -734,14 +831,15 @@ func (t *Terminal) sgrSequenceHandler(params []string) bool {
 		params = []string{"0"}
 	}
 
-	for i := range params {
+	for i := 0; i < len(params); i++ {
 
 		p := strings.Replace(strings.Replace(params[i], "[", "", -1), "]", "", -1)
 
 		switch p {
 		case "00", "0", "":
+			// hyperlinks are set with OSC 8 rather than SGR, so outlive a reset
 			attr := t.GetActiveBuffer().getCursorAttr()
-			*attr = CellAttributes{}
+			*attr = CellAttributes{hyperlink: attr.hyperlink}
 		case "1", "01":
 			t.GetActiveBuffer().getCursorAttr().bold = true
 		case "2", "02":
@@ -770,20 +868,49 @@ func (t *Terminal) sgrSequenceHandler(params []string) bool {
 			t.GetActiveBuffer().getCursorAttr().hidden = false
 		case "29":
 			// not strikethrough
-		case "38": // set foreground
-			t.GetActiveBuffer().getCursorAttr().fgColour = Colour(p + ";" + strings.Join(params[i:], ";"))
-		case "48": // set background
-			t.GetActiveBuffer().getCursorAttr().bgColour = Colour(p + ";" + strings.Join(params[i:], ";"))
+		case "38", "48": // set extended foreground/background
+			colour, consumed := parseExtendedColour(params[i:])
+			i += consumed
+			if colour == "" {
+				return false
+			}
+			if p == "38" {
+				t.GetActiveBuffer().getCursorAttr().fgColour = colour
+			} else {
+				t.GetActiveBuffer().getCursorAttr().bgColour = colour
+			}
 		default:
-			i, err := strconv.Atoi(p)
+			if strings.HasPrefix(p, "38:") || strings.HasPrefix(p, "48:") {
+				// colon separated form e.g. 38:2::255:0:0
+				parts := strings.Split(p, ":")
+				if len(parts) == 6 && parts[1] == "2" {
+					parts = append(parts[:2], parts[3:]...)
+				}
+				colour, _ := parseExtendedColour(parts)
+				if colour == "" {
+					// leave the colour as it was, rather than resetting it, if it can't be parsed
+					continue
+				}
+				if strings.HasPrefix(p, "38") {
+					t.GetActiveBuffer().getCursorAttr().fgColour = colour
+				} else {
+					t.GetActiveBuffer().getCursorAttr().bgColour = colour
+				}
+				continue
+			}
+			n, err := strconv.Atoi(p)
 			if err != nil {
 				return false
 			}
 			switch true {
-			case i >= 30 && i <= 37, i >= 90 && i <= 97, i == 39:
+			case n >= 30 && n <= 37, n >= 90 && n <= 97:
 				t.GetActiveBuffer().getCursorAttr().fgColour = Colour(p)
-			case i >= 40 && i <= 47, i >= 100 && i <= 107, i == 49:
+			case n == 39:
+				t.GetActiveBuffer().getCursorAttr().fgColour = ""
+			case n >= 40 && n <= 47, n >= 100 && n <= 107:
 				t.GetActiveBuffer().getCursorAttr().bgColour = Colour(p)
+			case n == 49:
+				t.GetActiveBuffer().getCursorAttr().bgColour = ""
 			}
 
 		}
@@ -791,3 +918,40 @@ func (t *Terminal) sgrSequenceHandler(params []string) bool {
 
 	return false
 }
+
+// parseExtendedColour parses a 256 colour (38;5;n) or truecolor (38;2;r;g;b) sequence from the start of params,
+// returning the colour and the number of additional params consumed
+func parseExtendedColour(params []string) (colour Colour, consumed int) {
+	if len(params) < 2 {
+		return "", len(params) - 1
+	}
+	switch params[1] {
+	case "5":
+		if len(params) < 3 {
+			return "", len(params) - 1
+		}
+		if !validColourComponents(params[2:3]) {
+			return "", 2
+		}
+		return Colour(strings.Join(params[:3], ";")), 2
+	case "2":
+		if len(params) < 5 {
+			return "", len(params) - 1
+		}
+		if !validColourComponents(params[2:5]) {
+			return "", 4
+		}
+		return Colour(strings.Join(params[:5], ";")), 4
+	}
+	return "", 1
+}
+
+// validColourComponents returns true if every component is a palette index or colour value from 0 to 255
+func validColourComponents(components []string) bool {
+	for _, component := range components {
+		if v, err := strconv.Atoi(component); err != nil || v < 0 || v > 255 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package termutil
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// the most enhancements of the kitty keyboard protocol which can be pushed, after which the oldest are dropped
+const maxKeyboardFlags = 16
+
+// KeyboardFlags returns the enhancements of the kitty keyboard protocol the program has enabled
+func (t *Terminal) KeyboardFlags() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.keyboardFlags
+}
+
+// ModifyOtherKeys returns the xterm modifyOtherKeys level the program has chosen
+func (t *Terminal) ModifyOtherKeys() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.modifyOtherKeys
+}
+
+// CSI > flags u, CSI < count u, CSI = flags ; mode u and CSI ? u
+// Push, pop, set and query the enhancements of the kitty keyboard protocol
+func (t *Terminal) csiKeyboardFlagsHandler(params []string) (renderRequired bool) {
+	if len(params) == 0 {
+		return false
+	}
+	prefix, first := params[0][0], params[0][1:]
+	value := func(param string, fallback int) int {
+		if n, err := strconv.Atoi(param); err == nil {
+			return n
+		}
+		return fallback
+	}
+	switch prefix {
+	case '>':
+		t.keyboardFlagsStack = append(t.keyboardFlagsStack, t.keyboardFlags)
+		if len(t.keyboardFlagsStack) > maxKeyboardFlags {
+			t.keyboardFlagsStack = t.keyboardFlagsStack[1:]
+		}
+		t.keyboardFlags = value(first, 0)
+	case '<':
+		for count := value(first, 1); count > 0; count-- {
+			if len(t.keyboardFlagsStack) == 0 {
+				t.keyboardFlags = 0
+				break
+			}
+			t.keyboardFlags = t.keyboardFlagsStack[len(t.keyboardFlagsStack)-1]
+			t.keyboardFlagsStack = t.keyboardFlagsStack[:len(t.keyboardFlagsStack)-1]
+		}
+	case '=':
+		flags, mode := value(first, 0), 1
+		if len(params) > 1 {
+			mode = value(params[1], 1)
+		}
+		switch mode {
+		case 1:
+			t.keyboardFlags = flags
+		case 2:
+			t.keyboardFlags |= flags
+		case 3:
+			t.keyboardFlags &^= flags
+		}
+	case '?':
+		t.respondToPty([]byte(fmt.Sprintf("\x1b[?%du", t.keyboardFlags)))
+	}
+	return false
+}
+
+// CSI > Pp ; Pv m
+// Set key modifier options (XTMODKEYS). Only modifyOtherKeys (Pp = 4) is supported, and leaving out the value
+// resets it.
+func (t *Terminal) csiKeyModifierOptionsHandler(params []string) (renderRequired bool) {
+	if len(params) == 0 || strings.TrimPrefix(params[0], ">") != "4" {
+		return false
+	}
+	t.modifyOtherKeys = 0
+	if len(params) > 1 {
+		t.modifyOtherKeys, _ = strconv.Atoi(params[1])
+	}
+	return false
+}
+
+// resetKeyboard turns off every keyboard enhancement the program has asked for
+func (t *Terminal) resetKeyboard() {
+	t.keyboardFlags = 0
+	t.keyboardFlagsStack = nil
+	t.modifyOtherKeys = 0
+}
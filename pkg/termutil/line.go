@@ -18,6 +18,16 @@ func newLine() Line {
 	}
 }
 
+// Cells returns the cells which make up the line
+func (line *Line) Cells() []Cell {
+	return line.cells
+}
+
+// IsWrapped returns true if the line was wrapped onto from the previous one
+func (line *Line) IsWrapped() bool {
+	return line.wrapped
+}
+
 func (line *Line) reverseVideo() {
 	for i, _ := range line.cells {
 		line.cells[i].attr.reverseVideo()
@@ -48,11 +58,25 @@ func (line *Line) setNoBreak(nobreak bool) {
 }
 
 func (line *Line) String() string {
-	runes := []rune{}
+	var output strings.Builder
 	for _, cell := range line.cells {
-		runes = append(runes, cell.r.Rune)
+		output.WriteString(cell.String())
+	}
+	return strings.TrimRight(output.String(), " ")
+}
+
+// clearWide blanks the other half of a wide character which is about to be partly overwritten at x
+func (line *Line) clearWide(x int) {
+	if x < 0 || x >= len(line.cells) {
+		return
+	}
+	cell := &line.cells[x]
+	if cell.continuation && x > 0 {
+		line.cells[x-1].setRune(MeasuredRune{Rune: ' ', Width: 1})
+	}
+	if cell.r.Width > 1 && x+1 < len(line.cells) && line.cells[x+1].continuation {
+		line.cells[x+1].setRune(MeasuredRune{Rune: ' ', Width: 1})
 	}
-	return strings.TrimRight(string(runes), "\x00 ")
 }
 
 // @todo test these (ported from legacy) ------------------
@@ -1,5 +1,6 @@
 package termutil
 
+// MeasuredRune is a character and the number of columns it takes up
 type MeasuredRune struct {
 	Rune  rune
 	Width int
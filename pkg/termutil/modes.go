@@ -3,6 +3,7 @@ package termutil
 type Modes struct {
 	ShowCursor            bool
 	ApplicationCursorKeys bool
+	ApplicationKeypad     bool // DECKPAM - keypad keys send escape sequences rather than the characters on them
 	BlinkingCursor        bool
 	ReplaceMode           bool // overwrite character at cursor or insert new
 	OriginMode            bool // see DECOM docs - whether cursor is positioned within the margins or not
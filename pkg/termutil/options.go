@@ -0,0 +1,66 @@
+package termutil
+
+import (
+	"os"
+	"os/exec"
+)
+
+type Option func(t *Terminal)
+
+func WithLogFile(path string) Option {
+	return func(t *Terminal) {
+		t.logFile, _ = os.Create(path)
+	}
+}
+
+// WithClipboardHandler sets a function to be called when the child program sets the clipboard via OSC 52
+func WithClipboardHandler(handler func(selection string, data []byte)) Option {
+	return func(t *Terminal) {
+		t.clipboardHandler = handler
+	}
+}
+
+// WithBellHandler sets a function to be called when the child program rings the bell
+func WithBellHandler(handler func()) Option {
+	return func(t *Terminal) {
+		t.bellHandler = handler
+	}
+}
+
+// WithNotificationHandler sets a function to be called when the child program sends a desktop notification via
+// OSC 9 or OSC 777. The title is empty for OSC 9 notifications.
+func WithNotificationHandler(handler func(title string, body string)) Option {
+	return func(t *Terminal) {
+		t.notificationHandler = handler
+	}
+}
+
+// WithTitleHandler sets a function to be called when the child program changes the title of the terminal
+func WithTitleHandler(handler func(title string)) Option {
+	return func(t *Terminal) {
+		t.titleHandler = handler
+	}
+}
+
+// WithDynamicColourHandler sets a function which returns the colours reported when the child program queries the
+// default foreground (OSC 10) or background (OSC 11) colour, e.g. "rgb:ffff/ffff/ffff". The code is 10 or 11, and
+// queries aren't answered if the function returns an empty string.
+func WithDynamicColourHandler(handler func(code int) string) Option {
+	return func(t *Terminal) {
+		t.colourHandler = handler
+	}
+}
+
+// WithVersion sets the name and version reported when the child program sends an XTVERSION query, e.g. "sunder(1.0)"
+func WithVersion(version string) Option {
+	return func(t *Terminal) {
+		t.version = version
+	}
+}
+
+// WithCommand sets the command run in the terminal, instead of the user's shell
+func WithCommand(cmd *exec.Cmd) Option {
+	return func(t *Terminal) {
+		t.command = cmd
+	}
+}
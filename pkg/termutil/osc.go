@@ -0,0 +1,160 @@
+package termutil
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+func (t *Terminal) handleOSC(readChan chan MeasuredRune) (renderRequired bool) {
+
+	params := []string{}
+	param := ""
+
+	for {
+		b := t.readRune(readChan)
+		if t.isOSCTerminator(b.Rune) {
+			params = append(params, param)
+			break
+		}
+		if b.Rune == ';' {
+			params = append(params, param)
+			param = ""
+			continue
+		}
+		param = fmt.Sprintf("%s%c", param, b.Rune)
+	}
+
+	if len(params) == 0 {
+		return false
+	}
+
+	pT := params[len(params)-1]
+	pS := params[:len(params)-1]
+
+	if len(pS) == 0 {
+		pS = []string{pT}
+		pT = ""
+	}
+
+	switch pS[0] {
+	case "0", "2":
+		t.setTitle(pT)
+	case "10", "11": // get/set foreground and background colour
+		t.handleDynamicColours(params)
+	case "8": // hyperlink
+		if len(params) > 2 {
+			t.handleHyperlink(params[1], params[2:])
+		}
+	case "52": // manipulate selection data
+		if len(pS) > 1 {
+			t.handleClipboard(pS[1], pT)
+		}
+	case "9": // desktop notification
+		// ConEmu uses OSC 9 with a numeric parameter for other purposes, such as progress reporting
+		if len(params) > 2 {
+			if _, err := strconv.Atoi(params[1]); err == nil {
+				break
+			}
+		}
+		t.handleNotification("", params[1:])
+	case "777": // desktop notification (rxvt-unicode)
+		if len(params) > 3 && params[1] == "notify" {
+			t.handleNotification(params[2], params[3:])
+		}
+	}
+	return false
+}
+
+// OSC 9 ; Pt
+// OSC 777 ; notify ; Ptitle ; Pt
+// The body may include semicolons, so is made up of all of the remaining params.
+func (t *Terminal) handleNotification(title string, params []string) {
+	if t.notificationHandler == nil {
+		return
+	}
+	body := strings.TrimRight(strings.Join(params, ";"), "\x1b")
+	t.afterUnlock(func() {
+		t.notificationHandler(title, body)
+	})
+}
+
+// OSC 52 ; Pc ; Pd
+// Pc selects the clipboard(s), Pd is the base64 encoded data. Queries (Pd = "?") are not supported,
+// as we don't want child processes reading the contents of the user's clipboard.
+func (t *Terminal) handleClipboard(selection string, encoded string) {
+	if t.clipboardHandler == nil || encoded == "?" {
+		return
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimRight(encoded, "\x1b"))
+	if err != nil {
+		t.log("Invalid OSC 52 data: %s", err)
+		return
+	}
+	if selection == "" {
+		selection = "s0"
+	}
+	t.afterUnlock(func() {
+		t.clipboardHandler(selection, data)
+	})
+}
+
+// OSC Ps ; Pt ; Pt...
+// Each Pt sets or queries (Pt = "?") a dynamic colour, starting from Ps, so OSC 10 ; ? ; ? queries both the
+// foreground and background. Setting colours is ignored, as they belong to the terminal displaying the pane. Replies
+// end with the same terminator as the query.
+func (t *Terminal) handleDynamicColours(params []string) {
+	if t.colourHandler == nil {
+		return
+	}
+	first, _ := strconv.Atoi(params[0])
+	terminator := "\x07"
+	if strings.HasSuffix(params[len(params)-1], "\x1b") {
+		terminator = "\x1b\\"
+	}
+	for i, param := range params[1:] {
+		code := first + i
+		if strings.TrimRight(param, "\x1b") != "?" || code > 11 {
+			continue
+		}
+		if colour := t.colourHandler(code); colour != "" {
+			t.respondToPty([]byte(fmt.Sprintf("\x1b]%d;%s%s", code, colour, terminator)))
+		}
+	}
+}
+
+// OSC 8 ; params ; URI
+// Starts a hyperlink, which covers the text written until it is ended with an empty URI. The URI may include
+// semicolons, so is made up of all of the remaining params. The only param used is the id, which groups cells into
+// the same link.
+func (t *Terminal) handleHyperlink(linkParams string, params []string) {
+	uri := strings.TrimRight(strings.Join(params, ";"), "\x1b")
+	attr := t.GetActiveBuffer().getCursorAttr()
+	if uri == "" {
+		attr.hyperlink = nil
+		return
+	}
+	link := &Hyperlink{URI: uri}
+	for _, param := range strings.Split(linkParams, ":") {
+		if strings.HasPrefix(param, "id=") {
+			link.ID = strings.TrimPrefix(param, "id=")
+		}
+	}
+	// links are written back out when the pane is drawn, so must not be able to end the sequence early
+	if strings.IndexFunc(link.URI+link.ID, unicode.IsControl) >= 0 {
+		attr.hyperlink = nil
+		return
+	}
+	attr.hyperlink = link
+}
+
+func (t *Terminal) isOSCTerminator(r rune) bool {
+	for _, terminator := range oscTerminators {
+		if terminator == r {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package termutil
+
+import (
+	"errors"
+	"io"
+)
+
+// outputPipeQueue is the number of writes which can be waiting for an output pipe before it is dropped
+const outputPipeQueue = 4096
+
+// ErrOutputPipeFull is reported when an output pipe is dropped because it couldn't keep up with the program
+var ErrOutputPipeFull = errors.New("output pipe is not keeping up with the terminal")
+
+// outputPipe copies output to a writer in the background, so a slow writer can't hold up the terminal
+type outputPipe struct {
+	writer  io.Writer
+	queue   chan []byte
+	stop    chan struct{}
+	done    chan struct{}
+	onError func(err error)
+}
+
+// AddOutputPipe adds a writer which receives a copy of everything the child process writes. Writes happen in the
+// background, and if w returns an error or falls too far behind it is removed and onError, if it isn't nil, is
+// called with the reason.
+func (t *Terminal) AddOutputPipe(w io.Writer, onError func(err error)) {
+	pipe := &outputPipe{
+		writer:  w,
+		queue:   make(chan []byte, outputPipeQueue),
+		stop:    make(chan struct{}),
+		done:    make(chan struct{}),
+		onError: onError,
+	}
+	t.outputPipeLock.Lock()
+	t.outputPipes = append(t.outputPipes, pipe)
+	t.outputPipeLock.Unlock()
+	go pipe.run(t)
+}
+
+// RemoveOutputPipe stops copying output to a writer previously passed to AddOutputPipe. Output which has already
+// been queued is still written, and the returned channel is closed once w is no longer being written to, so it can
+// be closed.
+func (t *Terminal) RemoveOutputPipe(w io.Writer) <-chan struct{} {
+	t.outputPipeLock.Lock()
+	defer t.outputPipeLock.Unlock()
+	for i, pipe := range t.outputPipes {
+		if pipe.writer == w {
+			t.outputPipes = append(t.outputPipes[:i:i], t.outputPipes[i+1:]...)
+			close(pipe.stop)
+			return pipe.done
+		}
+	}
+	done := make(chan struct{})
+	close(done)
+	return done
+}
+
+// dropOutputPipe removes a pipe which has failed, and reports why
+func (t *Terminal) dropOutputPipe(pipe *outputPipe, err error) {
+	t.outputPipeLock.Lock()
+	removed := false
+	for i, p := range t.outputPipes {
+		if p == pipe {
+			t.outputPipes = append(t.outputPipes[:i:i], t.outputPipes[i+1:]...)
+			close(pipe.stop)
+			removed = true
+			break
+		}
+	}
+	t.outputPipeLock.Unlock()
+	if !removed {
+		return
+	}
+	t.log("Dropped output pipe: %s", err)
+	if pipe.onError != nil {
+		pipe.onError(err)
+	}
+}
+
+func (t *Terminal) writeToOutputPipes(data []byte) {
+	t.outputPipeLock.Lock()
+	pipes := append([]*outputPipe(nil), t.outputPipes...)
+	t.outputPipeLock.Unlock()
+	if len(pipes) == 0 {
+		return
+	}
+	// the caller reuses its buffer, and every pipe can share the copy as it is never modified
+	data = append([]byte(nil), data...)
+	for _, pipe := range pipes {
+		select {
+		case pipe.queue <- data:
+		default:
+			t.dropOutputPipe(pipe, ErrOutputPipeFull)
+		}
+	}
+}
+
+// run writes queued output until the pipe is removed, then writes whatever was queued before it was removed
+func (p *outputPipe) run(t *Terminal) {
+	defer close(p.done)
+	for {
+		select {
+		case data := <-p.queue:
+			if !p.write(t, data) {
+				return
+			}
+		case <-p.stop:
+			for {
+				select {
+				case data := <-p.queue:
+					if !p.write(t, data) {
+						return
+					}
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (p *outputPipe) write(t *Terminal, data []byte) bool {
+	if _, err := p.writer.Write(data); err != nil {
+		t.dropOutputPipe(p, err)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package termutil
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSGRSequences(t *testing.T) {
+	tests := []struct {
+		name     string
+		initial  CellAttributes
+		params   string
+		expected CellAttributes
+	}{
+		{"basic foreground", CellAttributes{}, "31", CellAttributes{fgColour: "31"}},
+		{"bright background", CellAttributes{}, "101", CellAttributes{bgColour: "101"}},
+		{"default colours", CellAttributes{fgColour: "31", bgColour: "42"}, "39;49", CellAttributes{}},
+		{"attributes", CellAttributes{}, "1;2;4;5;7;8", CellAttributes{bold: true, dim: true, underline: true, blink: true, inverse: true, hidden: true}},
+		{"attributes off", CellAttributes{bold: true, underline: true, fgColour: "31"}, "22;24;21", CellAttributes{fgColour: "31"}},
+		{"reset", CellAttributes{bold: true, fgColour: "31", bgColour: "42"}, "0", CellAttributes{}},
+		{"empty is a reset", CellAttributes{bold: true}, "", CellAttributes{}},
+		{"reset keeps hyperlink", CellAttributes{bold: true, hyperlink: &Hyperlink{URI: "https://example.com"}}, "0", CellAttributes{hyperlink: &Hyperlink{URI: "https://example.com"}}},
+		{"256 colour foreground", CellAttributes{}, "38;5;208", CellAttributes{fgColour: "38;5;208"}},
+		{"truecolour background", CellAttributes{}, "48;2;255;0;10", CellAttributes{bgColour: "48;2;255;0;10"}},
+		{"extended colour followed by attribute", CellAttributes{}, "38;5;208;1", CellAttributes{fgColour: "38;5;208", bold: true}},
+		{"colon 256 colour", CellAttributes{}, "38:5:208", CellAttributes{fgColour: "38;5;208"}},
+		{"colon truecolour with colour space", CellAttributes{}, "48:2::255:0:10", CellAttributes{bgColour: "48;2;255;0;10"}},
+		{"colon truecolour without colour space", CellAttributes{}, "38:2:255:0:10", CellAttributes{fgColour: "38;2;255;0;10"}},
+		{"invalid colon colour is ignored", CellAttributes{fgColour: "32"}, "38:5:300", CellAttributes{fgColour: "32"}},
+		{"incomplete colon colour is ignored", CellAttributes{bgColour: "42"}, "48:2:1:2", CellAttributes{bgColour: "42"}},
+		{"invalid truecolour is ignored", CellAttributes{fgColour: "32"}, "38;2;255;0;256", CellAttributes{fgColour: "32"}},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			term := New()
+			*term.GetActiveBuffer().getCursorAttr() = test.initial
+			var params []string
+			if test.params != "" {
+				params = strings.Split(test.params, ";")
+			}
+			term.sgrSequenceHandler(params)
+			actual := *term.GetActiveBuffer().getCursorAttr()
+			if actual.hyperlink != nil && test.expected.hyperlink != nil && *actual.hyperlink == *test.expected.hyperlink {
+				actual.hyperlink = test.expected.hyperlink
+			}
+			if actual != test.expected {
+				t.Fatalf("expected %+v, got %+v", test.expected, actual)
+			}
+		})
+	}
+}
+
+func TestGetDiffANSI(t *testing.T) {
+	tests := []struct {
+		name     string
+		previous CellAttributes
+		current  CellAttributes
+		expected string
+	}{
+		{"unchanged", CellAttributes{bold: true, fgColour: "31"}, CellAttributes{bold: true, fgColour: "31"}, ""},
+		{"attributes and colour added", CellAttributes{}, CellAttributes{bold: true, fgColour: "31"}, "\x1b[1;31m"},
+		{"attribute added", CellAttributes{bold: true, fgColour: "31"}, CellAttributes{bold: true, underline: true, fgColour: "31"}, "\x1b[4m"},
+		{"attribute removed resets", CellAttributes{bold: true, fgColour: "31"}, CellAttributes{fgColour: "31"}, "\x1b[0;31m"},
+		{"every attribute removed", CellAttributes{bold: true}, CellAttributes{}, "\x1b[0m"},
+		{"default foreground", CellAttributes{fgColour: "31"}, CellAttributes{}, "\x1b[39m"},
+		{"default background", CellAttributes{bgColour: "48;5;208"}, CellAttributes{}, "\x1b[49m"},
+		{"extended colours", CellAttributes{fgColour: "31"}, CellAttributes{fgColour: "38;2;1;2;3", bgColour: "48;5;17"}, "\x1b[38;2;1;2;3;48;5;17m"},
+	}
+	for _, test := range tests {
+		test := test
+		t.Run(test.name, func(t *testing.T) {
+			if actual := test.current.GetDiffANSI(test.previous); actual != test.expected {
+				t.Fatalf("expected %q, got %q", test.expected, actual)
+			}
+		})
+	}
+}
@@ -0,0 +1,471 @@
+package termutil
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/creack/pty"
+)
+
+const (
+	MainBuffer     uint8 = 0
+	AltBuffer      uint8 = 1
+	InternalBuffer uint8 = 2
+)
+
+// Terminal communicates with the underlying terminal which is running shox
+type Terminal struct {
+	// lock guards the state of the terminal, which is changed as output from the program is processed
+	lock sync.Mutex
+	// handlers and responses to the program, which are called once the lock is released
+	pending             []func()
+	pty                 *os.File
+	updateChan          chan struct{}
+	processChan         chan MeasuredRune
+	closeChan           chan struct{}
+	closeOnce           sync.Once
+	command             *exec.Cmd
+	buffers             []*Buffer
+	activeBuffer        *Buffer
+	title               string
+	logFile             *os.File
+	clipboardHandler    func(selection string, data []byte)
+	bellHandler         func()
+	notificationHandler func(title string, body string)
+	titleHandler        func(title string)
+	colourHandler       func(code int) string
+	exitCode            int
+	// the name and version reported in reply to XTVERSION
+	version string
+	// the cursor style set with DECSCUSR, or 0 for the terminal's default
+	cursorStyle int
+	// the program wants to know when the terminal gains and loses focus (mode 1004)
+	focusReporting bool
+	// keyboard enhancements the program has asked for: kitty keyboard protocol flags, with the flags they replaced,
+	// and the xterm modifyOtherKeys level
+	keyboardFlags      int
+	keyboardFlagsStack []int
+	modifyOtherKeys    int
+	// when the program started a synchronized update (mode 2026), or zero if it isn't drawing one
+	synchronizedSince time.Time
+	synchronizedTimer *time.Timer
+	synchronizedLock  sync.Mutex
+	outputPipes       []*outputPipe
+	outputPipeLock    sync.Mutex
+}
+
+// NewTerminal creates a new terminal instance
+func New(options ...Option) *Terminal {
+	term := &Terminal{
+		processChan: make(chan MeasuredRune, 0xffff),
+		closeChan:   make(chan struct{}),
+		version:     "termutil",
+	}
+	term.buffers = []*Buffer{
+		NewBuffer(1, 1, 0xffff),
+		NewBuffer(1, 1, 0xffff),
+		NewBuffer(1, 1, 0xffff),
+	}
+	term.activeBuffer = term.buffers[0]
+	for _, opt := range options {
+		opt(term)
+	}
+	return term
+}
+
+func (t *Terminal) log(line string, params ...interface{}) {
+	if t.logFile != nil {
+		_, _ = fmt.Fprintf(t.logFile, line+"\n", params...)
+	}
+}
+
+// Lock stops the terminal processing output, so its buffers can be read while the program is running. The other
+// methods of the terminal lock it themselves, so must not be called until Unlock is called.
+func (t *Terminal) Lock() {
+	t.lock.Lock()
+}
+
+// Unlock lets the terminal continue processing output after a call to Lock
+func (t *Terminal) Unlock() {
+	t.unlock()
+}
+
+// unlock releases the lock, then calls the handlers and sends the responses which were queued while it was held.
+// Handlers are called without the lock so they can draw, which involves locking the terminal to read it.
+func (t *Terminal) unlock() {
+	pending := t.pending
+	t.pending = nil
+	t.lock.Unlock()
+	for _, fn := range pending {
+		fn()
+	}
+}
+
+// afterUnlock queues a function to be called once the lock is released. It must be called with the lock held.
+func (t *Terminal) afterUnlock(fn func()) {
+	t.pending = append(t.pending, fn)
+}
+
+// Pty exposes the underlying terminal pty, if it exists
+func (t *Terminal) Pty() *os.File {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.pty
+}
+
+func (t *Terminal) GetTitle() string {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.title
+}
+
+// CursorStyle returns the cursor style the program has asked for with DECSCUSR, or 0 if it hasn't set one
+func (t *Terminal) CursorStyle() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.cursorStyle
+}
+
+// KeyModes returns whether the program has asked for application cursor keys (DECCKM) and application keypad keys
+// (DECKPAM)
+func (t *Terminal) KeyModes() (applicationCursorKeys bool, applicationKeypad bool) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	modes := t.activeBuffer.modes
+	return modes.ApplicationCursorKeys, modes.ApplicationKeypad
+}
+
+// write takes data from StdOut of the child shell and processes it
+func (t *Terminal) Write(data []byte) (n int, err error) {
+	t.writeToOutputPipes(data)
+	reader := bufio.NewReader(bytes.NewBuffer(data))
+	for {
+		r, _, err := reader.ReadRune()
+		if err == io.EOF {
+			break
+		}
+		select {
+		case t.processChan <- MeasuredRune{Rune: r, Width: RuneWidth(r)}:
+		case <-t.closeChan:
+			return len(data), io.ErrClosedPipe
+		}
+	}
+	return len(data), nil
+}
+
+// SetSize resizes the terminal, and the pty of the child process if it is running
+func (t *Terminal) SetSize(rows, cols uint16) error {
+
+	t.log("RESIZE %d, %d\n", cols, rows)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.activeBuffer.resizeView(cols, rows)
+	// the pty is resized with the lock held, so it can't be closed part way through
+	if t.pty == nil {
+		return nil
+	}
+	if err := pty.Setsize(t.pty, &pty.Winsize{
+		Rows: rows,
+		Cols: cols,
+	}); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Run starts the terminal/shell proxying process
+func (t *Terminal) Run(updateChan chan struct{}, rows uint16, cols uint16) error {
+
+	t.updateChan = updateChan
+
+	c := t.command
+	if c == nil {
+		shell := os.Getenv("SHELL")
+		if shell == "" {
+			shell = "/bin/sh"
+		}
+		c = exec.Command(shell)
+	}
+
+	// Start the command with a pty.
+	f, err := pty.Start(c)
+	if err != nil {
+		return err
+	}
+	t.lock.Lock()
+	t.pty = f
+	t.command = c
+	t.lock.Unlock()
+	// Make sure to close the pty at the end.
+	defer t.closePty()
+
+	if err := t.SetSize(rows, cols); err != nil {
+		return err
+	}
+
+	go t.process()
+
+	_, _ = io.Copy(t, f)
+	t.closeOnce.Do(func() { close(t.closeChan) })
+	// the output of the process has finished, so it has exited or is about to
+	if err := c.Wait(); err != nil {
+		t.log("Shell exited: %s", err)
+	}
+	t.exitCode = c.ProcessState.ExitCode()
+	return nil
+}
+
+// RunHeadless processes everything written to the terminal without running a child process, until Close is called.
+// This lets the terminal be used as a virtual screen, e.g. to check what a program has drawn.
+func (t *Terminal) RunHeadless(updateChan chan struct{}, rows uint16, cols uint16) {
+	t.updateChan = updateChan
+	_ = t.SetSize(rows, cols)
+	go t.process()
+}
+
+// Close hangs up the child process and stops processing output
+func (t *Terminal) Close() {
+	t.closeOnce.Do(func() { close(t.closeChan) })
+	t.lock.Lock()
+	command := t.command
+	t.lock.Unlock()
+	if command != nil && command.Process != nil {
+		_ = command.Process.Signal(syscall.SIGHUP)
+	}
+	// closing the pty also hangs up anything the shell is running in the foreground
+	t.closePty()
+}
+
+// closePty closes the pty, if it is open. Once it is closed the terminal stops sending anything to it.
+func (t *Terminal) closePty() {
+	t.lock.Lock()
+	f := t.pty
+	t.pty = nil
+	t.lock.Unlock()
+	if f != nil {
+		_ = f.Close()
+	}
+}
+
+// ReportFocus tells the program that the terminal has gained or lost focus, if it has asked to be told
+func (t *Terminal) ReportFocus(focused bool) {
+	t.lock.Lock()
+	defer t.unlock()
+	if !t.focusReporting {
+		return
+	}
+	if focused {
+		t.respondToPty([]byte("\x1b[I"))
+	} else {
+		t.respondToPty([]byte("\x1b[O"))
+	}
+}
+
+// ExitCode returns the exit status of the shell once Run has returned. It is -1 if the shell was killed by a signal.
+func (t *Terminal) ExitCode() int {
+	return t.exitCode
+}
+
+// synchronizedUpdateTimeout is how long a program can hold back rendering with a synchronized update, in case it
+// never finishes it
+const synchronizedUpdateTimeout = time.Second
+
+// InSynchronizedUpdate returns true while the program is part way through drawing a synchronized update (mode
+// 2026), so the terminal should not be rendered yet
+func (t *Terminal) InSynchronizedUpdate() bool {
+	t.synchronizedLock.Lock()
+	defer t.synchronizedLock.Unlock()
+	return !t.synchronizedSince.IsZero() && time.Since(t.synchronizedSince) < synchronizedUpdateTimeout
+}
+
+func (t *Terminal) setSynchronizedUpdate(enabled bool) {
+	t.synchronizedLock.Lock()
+	if t.synchronizedTimer != nil {
+		t.synchronizedTimer.Stop()
+		t.synchronizedTimer = nil
+	}
+	if enabled {
+		t.synchronizedSince = time.Now()
+		t.synchronizedTimer = time.AfterFunc(synchronizedUpdateTimeout, t.requestRender)
+	} else {
+		t.synchronizedSince = time.Time{}
+	}
+	t.synchronizedLock.Unlock()
+	if !enabled {
+		t.requestRender()
+	}
+}
+
+func (t *Terminal) requestRender() {
+	if t.InSynchronizedUpdate() {
+		// rendered when the update is finished
+		return
+	}
+	select {
+	case t.updateChan <- struct{}{}:
+	default:
+	}
+}
+
+func (t *Terminal) writeToRealStdOut(data ...rune) error {
+	_, err := os.Stdout.Write([]byte(string(data)))
+	return err
+}
+
+// respondToPty sends data to the program once the lock is released, as the program may not be reading its input.
+// It must be called with the lock held.
+func (t *Terminal) respondToPty(data []byte) {
+	f := t.pty
+	if f == nil {
+		return
+	}
+	t.afterUnlock(func() {
+		_, _ = f.Write(data)
+	})
+}
+
+func (t *Terminal) process() {
+	for {
+		select {
+		case <-t.closeChan:
+			return
+		case mr := <-t.processChan:
+			var renderRequired bool
+			t.lock.Lock()
+			if mr.Rune == 0x1b { // ANSI escape char, which means this is a sequence
+				renderRequired = t.handleANSI(t.processChan)
+			} else { // otherwise it's just an individual rune we need to process
+				renderRequired = t.processRunes(mr)
+			}
+			t.unlock()
+			if renderRequired {
+				t.requestRender()
+			}
+		}
+	}
+}
+
+// readRune reads the next rune of a sequence. The lock is released while waiting for it, so a program which stops
+// part way through a sequence doesn't stop the terminal being read.
+func (t *Terminal) readRune(readChan chan MeasuredRune) MeasuredRune {
+	select {
+	case r := <-readChan:
+		return r
+	default:
+	}
+	t.unlock()
+	r := <-readChan
+	t.lock.Lock()
+	return r
+}
+
+func (t *Terminal) processRunes(runes ...MeasuredRune) (renderRequired bool) {
+
+	for _, r := range runes {
+
+		t.log("%c 0x%X", r.Rune, r.Rune)
+
+		switch r.Rune {
+		case 0x05: //enq
+			continue
+		case 0x07: //bell
+			if t.bellHandler != nil {
+				t.afterUnlock(t.bellHandler)
+			}
+			continue
+		case 0x8: //backspace
+			t.GetActiveBuffer().backspace()
+			renderRequired = true
+		case 0x9: //tab
+			t.GetActiveBuffer().tab()
+			renderRequired = true
+		case 0xa, 0xc: //newLine/form feed
+			t.GetActiveBuffer().newLine()
+			renderRequired = true
+		case 0xb: //vertical tab
+			t.GetActiveBuffer().verticalTab()
+			renderRequired = true
+		case 0xd: //carriageReturn
+			t.GetActiveBuffer().carriageReturn()
+			renderRequired = true
+		case 0xe: //shiftOut
+			t.GetActiveBuffer().currentCharset = 1
+		case 0xf: //shiftIn
+			t.GetActiveBuffer().currentCharset = 0
+		default:
+			if r.Rune < 0x20 || (r.Rune >= 0x7f && r.Rune < 0xa0) {
+				// TODO handle any other control chars here
+				continue
+			}
+
+			t.GetActiveBuffer().write(t.translateRune(r))
+			renderRequired = true
+		}
+	}
+
+	return renderRequired
+}
+
+func (t *Terminal) translateRune(b MeasuredRune) MeasuredRune {
+	table := t.GetActiveBuffer().charsets[t.GetActiveBuffer().currentCharset]
+	if table == nil {
+		return b
+	}
+	chr, ok := (*table)[b.Rune]
+	if ok {
+		return MeasuredRune{Rune: chr, Width: 1}
+	}
+	return b
+}
+
+func (t *Terminal) setTitle(title string) {
+	changed := title != t.title
+	t.title = title
+	if changed && t.titleHandler != nil {
+		t.afterUnlock(func() {
+			t.titleHandler(title)
+		})
+	}
+}
+
+func (t *Terminal) switchBuffer(index uint8) {
+	var carrySize bool
+	var w, h uint16
+	var cursorKeys, keypad bool
+	if t.activeBuffer != nil {
+		w, h = t.activeBuffer.viewWidth, t.activeBuffer.viewHeight
+		carrySize = true
+		cursorKeys, keypad = t.activeBuffer.modes.ApplicationCursorKeys, t.activeBuffer.modes.ApplicationKeypad
+	}
+	t.activeBuffer = t.buffers[index]
+	if carrySize {
+		t.activeBuffer.resizeView(w, h)
+		// key modes belong to the terminal rather than the screen, so programs can set them before switching
+		t.activeBuffer.modes.ApplicationCursorKeys = cursorKeys
+		t.activeBuffer.modes.ApplicationKeypad = keypad
+	}
+}
+
+// GetActiveBuffer returns the buffer being drawn to. While the program is running, the terminal must be locked
+// while the buffer is used.
+func (t *Terminal) GetActiveBuffer() *Buffer {
+	return t.activeBuffer
+}
+
+func (t *Terminal) useMainBuffer() {
+	t.switchBuffer(MainBuffer)
+}
+
+func (t *Terminal) useAltBuffer() {
+	t.switchBuffer(AltBuffer)
+}